@@ -0,0 +1,502 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"time"
+
+	"github.com/cetteup/conman/pkg/game"
+	"github.com/rs/zerolog/log"
+
+	"github.com/cetteup/bf2-migrator/cmd/bf2-migrator/internal/config"
+	"github.com/cetteup/bf2-migrator/cmd/bf2-migrator/internal/ensure"
+	"github.com/cetteup/bf2-migrator/cmd/bf2-migrator/internal/migrate"
+	"github.com/cetteup/bf2-migrator/cmd/bf2-migrator/internal/patch"
+)
+
+const (
+	exitCodeSuccess = 0
+	exitCodeFailure = 1
+)
+
+// stdin is shared by all prompts, since a reader per prompt could buffer (and thus swallow) answers to later prompts,
+// e.g. when answers are piped in
+var stdin = bufio.NewReader(os.Stdin)
+
+type cliOptions struct {
+	// Title is the game whose profiles are migrated
+	Title              migrate.Title
+	InstallDir         string
+	ScanRoot           string
+	ReportPath         string
+	PatchOpenSpy       bool
+	DryRun             bool
+	SkipBF2HubRegistry bool
+	Yes                bool
+	// Interactive asks for confirmation before applying each modification when patching
+	Interactive bool
+	// Delay is the pause between migrating profiles
+	Delay time.Duration
+	// VerifyStatePath is where progress of verifying installations is persisted to, so it can be resumed
+	VerifyStatePath string
+	// VerifyDelay is the pause between verifying installations
+	VerifyDelay time.Duration
+}
+
+func runCheckConfig(path string) int {
+	if path == "" {
+		p, err := config.Path()
+		if err != nil {
+			log.Error().Err(err).Msg("Failed to determine config file path")
+			return exitCodeFailure
+		}
+		path = p
+	}
+
+	problems, err := config.Check(path)
+	if err != nil {
+		log.Error().Err(err).Str("path", path).Msg("Failed to read config file")
+		return exitCodeFailure
+	}
+
+	if len(problems) == 0 {
+		log.Info().Str("path", path).Msg("Config file is valid")
+		return exitCodeSuccess
+	}
+
+	for _, problem := range problems {
+		fmt.Printf("%s: %s\n", path, problem)
+	}
+
+	return exitCodeFailure
+}
+
+func runRevertForUninstall(r patch.RegistryRepository, f patch.Finder, opts cliOptions) int {
+	dir, err := resolveInstallDir(f, opts.InstallDir)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to detect game installation folder, please specify it via -install-dir")
+		return exitCodeFailure
+	}
+
+	result, err := patch.RevertForUninstall(r, dir)
+	if err != nil {
+		log.Error().Err(err).Str("dir", dir).Msg("Failed to revert installation for uninstall")
+		return exitCodeFailure
+	}
+
+	if result.NoOp {
+		log.Info().Str("dir", dir).Msg("Installation already stock, game can now be uninstalled")
+	} else {
+		log.Info().Str("dir", dir).Msg("Reverted installation to stock, game can now be uninstalled")
+	}
+	return exitCodeSuccess
+}
+
+func runRevertAll(r patch.RegistryRepository, f patch.Finder, opts cliOptions) int {
+	dirs, err := resolveInstallDirs(f, opts)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to detect game installation folders, please specify one via -install-dir")
+		return exitCodeFailure
+	}
+
+	if opts.DryRun {
+		for _, dir := range dirs {
+			plan, err2 := patch.PlanPatch(dir, patch.GameSpy)
+			if err2 != nil {
+				fmt.Printf("%s: cannot be reverted (%s)\n", dir, err2)
+				continue
+			}
+			fmt.Printf("%s:\n%s\n\n", dir, plan.Summary())
+		}
+		fmt.Printf("%s patching would be re-enabled (if installed)\n", patch.BF2Hub.DisplayName)
+		return exitCodeSuccess
+	}
+
+	question := fmt.Sprintf("Revert %d installation(s) to %s and re-enable %s patching", len(dirs), patch.GameSpy.DisplayName, patch.BF2Hub.DisplayName)
+	if !opts.Yes && !confirm(question+"?") {
+		log.Info().Msg("Aborted by user")
+		return exitCodeFailure
+	}
+
+	var report strings.Builder
+	exitCode := exitCodeSuccess
+	for _, dir := range dirs {
+		result, err2 := patch.RevertForUninstall(r, dir)
+		if err2 != nil {
+			log.Error().Err(err2).Str("dir", dir).Msg("Failed to revert installation")
+			fmt.Fprintf(&report, "%s: failed to revert (%s)\n", dir, err2)
+			exitCode = exitCodeFailure
+			continue
+		}
+
+		if result.NoOp {
+			log.Info().Str("dir", dir).Msg("Installation already stock")
+			fmt.Fprintf(&report, "%s: already stock, no changes\n", dir)
+		} else {
+			log.Info().Str("dir", dir).Msg("Reverted installation")
+			fmt.Fprintf(&report, "%s: reverted to %s\n", dir, patch.GameSpy.DisplayName)
+		}
+	}
+
+	if opts.ReportPath != "" {
+		if err = os.WriteFile(opts.ReportPath, []byte(report.String()), 0o644); err != nil {
+			log.Error().Err(err).Str("path", opts.ReportPath).Msg("Failed to write report")
+			return exitCodeFailure
+		}
+	}
+
+	return exitCode
+}
+
+func runVerify(f patch.Finder, opts cliOptions, name string) int {
+	target, err := patch.ParseProvider(name)
+	if err != nil {
+		log.Error().Msg(err.Error())
+		return exitCodeFailure
+	}
+
+	// Only find installations here, their binaries are read one by one below (with pauses in between)
+	var dirs []string
+	if opts.InstallDir == "" && opts.ScanRoot != "" {
+		dirs, err = patch.FindInstallDirs(opts.ScanRoot, patch.DefaultScanDepth)
+		if err == nil && len(dirs) == 0 {
+			err = fmt.Errorf("no game installations found in %s", opts.ScanRoot)
+		}
+	} else {
+		dirs, err = resolveInstallDirs(f, opts)
+	}
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to find game installation folders, please specify one via -install-dir or -scan")
+		return exitCodeFailure
+	}
+
+	statePath := opts.VerifyStatePath
+	if statePath == "" {
+		statePath = patch.DefaultVerifyStatePath()
+	}
+	state, err := patch.LoadVerifyState(statePath, time.Now())
+	if err != nil {
+		log.Error().Err(err).Str("path", statePath).Msg("Failed to load verification progress, delete the file to start over")
+		return exitCodeFailure
+	}
+
+	// Allow cancelling the verification (including any pause between installations) via Ctrl+C
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	results := make([]patch.VerifyResult, 0, len(dirs))
+	verified := 0
+	for _, dir := range dirs {
+		if result, ok := state.Verified(dir); ok {
+			log.Debug().Str("dir", dir).Msg("Skipping installation verified before being interrupted")
+			results = append(results, result)
+			continue
+		}
+
+		if verified > 0 {
+			if err = migrate.Pause(ctx, opts.VerifyDelay); err != nil {
+				log.Warn().Str("path", statePath).Msg("Cancelled by user, run again to resume verification")
+				return exitCodeFailure
+			}
+		}
+		verified++
+
+		result := patch.VerifyInstall(dir)
+		if result.Error != "" {
+			log.Warn().Str("dir", dir).Str("error", result.Error).Msg("Failed to verify installation")
+		} else {
+			log.Info().Str("dir", dir).Str("provider", result.Provider).Msg("Verified installation")
+		}
+		results = append(results, result)
+
+		state.Results = append(state.Results, result)
+		if err = patch.SaveVerifyState(statePath, state); err != nil {
+			// Not being able to resume is no reason to stop verifying
+			log.Warn().Err(err).Str("path", statePath).Msg("Failed to save verification progress")
+		}
+	}
+
+	report := patch.FormatVerifyReport(results, target)
+	fmt.Print(report)
+
+	if err = patch.DiscardVerifyState(statePath); err != nil {
+		log.Warn().Err(err).Str("path", statePath).Msg("Failed to delete verification progress")
+	}
+
+	if opts.ReportPath != "" {
+		if err = os.WriteFile(opts.ReportPath, []byte(report), 0o644); err != nil {
+			log.Error().Err(err).Str("path", opts.ReportPath).Msg("Failed to write report")
+			return exitCodeFailure
+		}
+	}
+
+	for _, result := range results {
+		if !result.UsesProvider(target) {
+			return exitCodeFailure
+		}
+	}
+
+	return exitCodeSuccess
+}
+
+func runPatch(r patch.RegistryRepository, f patch.Finder, opts cliOptions, name string) int {
+	p, err := patch.ParseProvider(name)
+	if err != nil {
+		log.Error().Msg(err.Error())
+		return exitCodeFailure
+	}
+
+	if opts.DryRun {
+		dir, err2 := resolveInstallDir(f, opts.InstallDir)
+		if err2 != nil {
+			log.Error().Err(err2).Msg("Failed to detect game installation folder, please specify it via -install-dir")
+			return exitCodeFailure
+		}
+
+		plan, err2 := patch.PlanPatch(dir, p)
+		if err2 != nil {
+			log.Error().Err(err2).Msg("Failed to determine required changes")
+			return exitCodeFailure
+		}
+		fmt.Println(plan.Summary())
+		return exitCodeSuccess
+	}
+
+	result, err := patchInstall(r, f, opts, p)
+	if errors.Is(err, patch.ErrAborted) {
+		log.Info().Msg("Aborted by user")
+		return exitCodeFailure
+	} else if err != nil {
+		log.Error().Err(err).Msg("Failed to patch game installation")
+		return exitCodeFailure
+	}
+
+	log.Info().Msg(result.String())
+	return exitCodeSuccess
+}
+
+func runAutoMigrateAll(h game.Handler, c migrate.Client, r patch.RegistryRepository, f patch.Finder, opts cliOptions) int {
+	profiles, _, err := opts.Title.GetProfiles(h)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to load list of available profiles")
+		return exitCodeFailure
+	}
+
+	// Singleplayer profiles don't have an account and thus cannot be migrated
+	eligible := make([]game.Profile, 0, len(profiles))
+	for _, profile := range profiles {
+		if profile.Type == game.ProfileTypeMultiplayer {
+			eligible = append(eligible, profile)
+		}
+	}
+
+	if opts.DryRun {
+		return runAutoMigrateAllDryRun(h, f, opts, eligible)
+	}
+
+	question := fmt.Sprintf("Migrate %d profile(s) to %s", len(eligible), patch.OpenSpy.DisplayName)
+	if opts.PatchOpenSpy {
+		question += fmt.Sprintf(" and patch %s to use %s", patch.BF2ExecutableName, patch.OpenSpy.DisplayName)
+	}
+	if !opts.Yes && !confirm(question+"?") {
+		log.Info().Msg("Aborted by user")
+		return exitCodeFailure
+	}
+
+	// Allow cancelling the batch (including any pause between profiles) via Ctrl+C
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	var report strings.Builder
+	exitCode := exitCodeSuccess
+	for i, profile := range eligible {
+		if i > 0 {
+			if err = migrate.Pause(ctx, opts.Delay); err != nil {
+				log.Warn().Msg("Cancelled by user")
+				for _, skipped := range eligible[i:] {
+					fmt.Fprintf(&report, "Profile %q: skipped (cancelled)\n", skipped.Name)
+				}
+				exitCode = exitCodeFailure
+				break
+			}
+		}
+
+		if err = opts.Title.MigrateProfile(h, c, profile); err != nil {
+			log.Error().Err(err).Str("profile", profile.Name).Msg("Failed to migrate profile")
+			fmt.Fprintf(&report, "Profile %q: failed (%s)\n", profile.Name, err)
+			exitCode = exitCodeFailure
+			continue
+		}
+
+		log.Info().Str("profile", profile.Name).Msg("Migrated profile")
+		fmt.Fprintf(&report, "Profile %q: migrated\n", profile.Name)
+	}
+
+	if opts.PatchOpenSpy && ctx.Err() != nil {
+		fmt.Fprintf(&report, "%s: skipped (cancelled)\n", patch.BF2ExecutableName)
+	} else if opts.PatchOpenSpy {
+		if _, err = patchInstall(r, f, opts, patch.OpenSpy); err != nil {
+			log.Error().Err(err).Msg("Failed to patch game installation")
+			fmt.Fprintf(&report, "%s: failed to patch (%s)\n", patch.BF2ExecutableName, err)
+			exitCode = exitCodeFailure
+		} else {
+			log.Info().Msg("Patched game installation")
+			fmt.Fprintf(&report, "%s: patched to use %s\n", patch.BF2ExecutableName, patch.OpenSpy.DisplayName)
+		}
+	}
+
+	if opts.ReportPath != "" {
+		if err = os.WriteFile(opts.ReportPath, []byte(report.String()), 0o644); err != nil {
+			log.Error().Err(err).Str("path", opts.ReportPath).Msg("Failed to write report")
+			return exitCodeFailure
+		}
+	}
+
+	return exitCode
+}
+
+func runEligibilityReport(h game.Handler, opts cliOptions, path string) int {
+	profiles, _, err := opts.Title.GetProfiles(h)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to load list of available profiles")
+		return exitCodeFailure
+	}
+
+	results := make([]migrate.Eligibility, 0, len(profiles))
+	for _, profile := range profiles {
+		results = append(results, opts.Title.CheckEligibility(h, profile))
+	}
+
+	var report strings.Builder
+	if err = migrate.WriteEligibilityCSV(&report, results); err != nil {
+		log.Error().Err(err).Msg("Failed to encode eligibility report")
+		return exitCodeFailure
+	}
+
+	if err = os.WriteFile(path, []byte(report.String()), 0o644); err != nil {
+		log.Error().Err(err).Str("path", path).Msg("Failed to write eligibility report")
+		return exitCodeFailure
+	}
+
+	log.Info().Str("path", path).Int("profiles", len(results)).Msg("Wrote eligibility report")
+	return exitCodeSuccess
+}
+
+func runAutoMigrateAllDryRun(h game.Handler, f patch.Finder, opts cliOptions, profiles []game.Profile) int {
+	if opts.PatchOpenSpy {
+		dir, err := resolveInstallDir(f, opts.InstallDir)
+		if err != nil {
+			log.Error().Err(err).Msg("Failed to detect game installation folder, please specify it via -install-dir")
+			return exitCodeFailure
+		}
+
+		report, err := ensure.DryRun(opts.Title, h, dir)
+		if err != nil {
+			log.Error().Err(err).Msg("Failed to determine required changes")
+			return exitCodeFailure
+		}
+		fmt.Print(report)
+		return exitCodeSuccess
+	}
+
+	for _, profile := range profiles {
+		fmt.Println(opts.Title.PlanProfile(h, profile))
+	}
+
+	return exitCodeSuccess
+}
+
+func patchInstall(r patch.RegistryRepository, f patch.Finder, opts cliOptions, p patch.Provider) (patch.Result, error) {
+	dir, err := resolveInstallDir(f, opts.InstallDir)
+	if err != nil {
+		return patch.Result{}, err
+	}
+
+	launchers, err := patch.DetectRunningLaunchers()
+	if err != nil {
+		return patch.Result{}, err
+	}
+	if len(launchers) > 0 {
+		log.Warn().Strs("launchers", launchers).Msg("Launchers are running and may relaunch the game while patching, consider closing them first")
+	}
+
+	// Confirm every step before preparing, since preparing closes processes and modifies BF2Hub's settings
+	if opts.Interactive {
+		err = patch.ConfirmSteps(dir, p, func(s patch.Step) bool {
+			return confirm(s.String() + ". Apply?")
+		})
+		if err != nil {
+			return patch.Result{}, err
+		}
+	}
+
+	mayRepatch, err := patch.PrepareForPatch(r, opts.SkipBF2HubRegistry)
+	if err != nil {
+		return patch.Result{}, err
+	}
+
+	if mayRepatch {
+		log.Warn().Msgf("BF2Hub is installed and its settings were not modified, it may re-patch %s", patch.BF2ExecutableName)
+	}
+
+	return patch.PatchBinary(dir, p)
+}
+
+func resolveInstallDir(f patch.Finder, dir string) (string, error) {
+	if dir != "" {
+		return dir, nil
+	}
+
+	return patch.DetectInstallPath(f)
+}
+
+func resolveInstallDirs(f patch.Finder, opts cliOptions) ([]string, error) {
+	if opts.InstallDir != "" {
+		return []string{opts.InstallDir}, nil
+	}
+
+	if opts.ScanRoot != "" {
+		return scanInstallDirs(opts.ScanRoot)
+	}
+
+	return patch.DetectInstallPaths(f)
+}
+
+func scanInstallDirs(root string) ([]string, error) {
+	found, err := patch.ScanInstalls(root, patch.DefaultScanDepth)
+	if err != nil {
+		return nil, err
+	}
+
+	dirs := make([]string, 0, len(found))
+	for _, install := range found {
+		if install.Err != nil {
+			log.Warn().Err(install.Err).Str("dir", install.Dir).Msg("Found game installation using an unknown provider")
+		} else {
+			log.Info().Str("dir", install.Dir).Str("provider", install.Provider.Name).Msg("Found game installation")
+		}
+		dirs = append(dirs, install.Dir)
+	}
+
+	if len(dirs) == 0 {
+		return nil, fmt.Errorf("no game installations found in %s", root)
+	}
+
+	return dirs, nil
+}
+
+func confirm(question string) bool {
+	fmt.Printf("%s [y/N] ", question)
+	answer, err := stdin.ReadString('\n')
+	if err != nil {
+		return false
+	}
+
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	return answer == "y" || answer == "yes"
+}
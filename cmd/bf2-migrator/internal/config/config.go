@@ -0,0 +1,144 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+const (
+	dirName  = "bf2-migrator"
+	fileName = "config.json"
+)
+
+type Config struct {
+	AlwaysOnTop        bool `json:"alwaysOnTop"`
+	SkipBF2HubRegistry bool `json:"skipBF2HubRegistry"`
+	// SkipProfileOwnerCheck disables the warning shown before migrating a profile owned by another Windows user
+	SkipProfileOwnerCheck bool `json:"skipProfileOwnerCheck"`
+	// ConfirmEachChange asks for confirmation before applying each modification when patching
+	ConfirmEachChange bool `json:"confirmEachChange"`
+	// ReviewProcesses shows the game/BF2Hub processes about to be closed before patching, allowing to deselect any
+	ReviewProcesses bool `json:"reviewProcesses"`
+	// MinimizeToTray adds an icon with quick actions to the system tray and hides the window there when minimized
+	MinimizeToTray bool `json:"minimizeToTray"`
+	// ProfileMigrationMillis is the average time migrating a single profile took during the last batch migration, zero
+	// if none has been recorded yet
+	ProfileMigrationMillis int64 `json:"profileMigrationMillis"`
+
+	path string
+}
+
+// Path returns the path of the config file in the user's config directory
+func Path() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(dir, dirName, fileName), nil
+}
+
+// Load reads the config from the user's config directory, returning the defaults if no config has been saved yet
+func Load() (*Config, error) {
+	path, err := Path()
+	if err != nil {
+		return nil, err
+	}
+
+	return LoadFrom(path)
+}
+
+// New returns a config with the default values, which is saved to the given path
+func New(path string) *Config {
+	return &Config{
+		path: path,
+	}
+}
+
+func LoadFrom(path string) (*Config, error) {
+	c := New(path)
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return c, nil
+		}
+		return nil, err
+	}
+
+	if err = json.Unmarshal(data, c); err != nil {
+		return nil, err
+	}
+
+	return c, nil
+}
+
+func (c *Config) Save() error {
+	if c.path == "" {
+		return fmt.Errorf("no config file path set")
+	}
+
+	data, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	if err = os.MkdirAll(filepath.Dir(c.path), 0o755); err != nil {
+		return err
+	}
+
+	return os.WriteFile(c.path, data, 0o644)
+}
+
+// Check validates the config file at the given path, returning a description of every problem found (rather than
+// just the first, as loading would). A missing file is not a problem, since the defaults are used in that case.
+func Check(path string) ([]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var values map[string]json.RawMessage
+	if err = json.Unmarshal(data, &values); err != nil {
+		return []string{fmt.Sprintf("not a valid JSON object: %s", err)}, nil
+	}
+
+	fields := map[string]reflect.Type{}
+	t := reflect.TypeOf(Config{})
+	for i := 0; i < t.NumField(); i++ {
+		if name, _, _ := strings.Cut(t.Field(i).Tag.Get("json"), ","); name != "" && name != "-" {
+			fields[name] = t.Field(i).Type
+		}
+	}
+
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	problems := make([]string, 0)
+	for _, key := range keys {
+		// Keys are matched case-sensitively here, since anything else is most likely a typo
+		typ, ok := fields[key]
+		if !ok {
+			problems = append(problems, fmt.Sprintf("unknown key %q", key))
+			continue
+		}
+
+		if err = json.Unmarshal(values[key], reflect.New(typ).Interface()); err != nil {
+			problems = append(problems, fmt.Sprintf("invalid value for %q: expected %s, got %s", key, typ, values[key]))
+		}
+	}
+
+	return problems, nil
+}
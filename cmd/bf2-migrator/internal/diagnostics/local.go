@@ -0,0 +1,72 @@
+package diagnostics
+
+import (
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	gpspPort = 29901
+	// localTimeout is much shorter than the regular timeout, since connections to localhost are either accepted or
+	// refused right away
+	localTimeout = 500 * time.Millisecond
+)
+
+// LocalService is a GameSpy service which might be provided by a local emulator
+type LocalService struct {
+	Name string
+	Port int
+}
+
+// localServices contains the GameSpy services (login and search) a local emulator would need to intercept
+var localServices = []LocalService{
+	{Name: "gpcm", Port: gpcmPort},
+	{Name: "gpsp", Port: gpspPort},
+}
+
+// CheckLocalListeners returns all GameSpy services which something (usually a local GameSpy emulator) is listening for on
+// localhost. All services are checked in parallel.
+func CheckLocalListeners() []LocalService {
+	listening := make([]bool, len(localServices))
+	var wg sync.WaitGroup
+	for i, service := range localServices {
+		wg.Add(1)
+		go func(i int, service LocalService) {
+			defer wg.Done()
+			conn, err := net.DialTimeout("tcp", net.JoinHostPort("127.0.0.1", fmt.Sprint(service.Port)), localTimeout)
+			if err != nil {
+				return
+			}
+			_ = conn.Close()
+			listening[i] = true
+		}(i, service)
+	}
+	wg.Wait()
+
+	found := make([]LocalService, 0, len(localServices))
+	for i, service := range localServices {
+		if listening[i] {
+			found = append(found, service)
+		}
+	}
+
+	return found
+}
+
+// FormatLocalListeners describes the local listeners and how they may interfere with the given provider, empty if there
+// are none
+func FormatLocalListeners(services []LocalService, provider string) string {
+	if len(services) == 0 {
+		return ""
+	}
+
+	names := make([]string, 0, len(services))
+	for _, s := range services {
+		names = append(names, fmt.Sprintf("%s (port %d)", s.Name, s.Port))
+	}
+
+	return fmt.Sprintf("Something is listening for %s on this computer, most likely a local GameSpy emulator or leftover server software. It may intercept connections meant for %s and cause login or server list problems. Consider stopping it.", strings.Join(names, ", "), provider)
+}
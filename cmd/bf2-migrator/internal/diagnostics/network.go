@@ -0,0 +1,121 @@
+package diagnostics
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+)
+
+const (
+	gpcmPort = 29900
+	timeout  = 5 * time.Second
+)
+
+type Target struct {
+	Name     string
+	Hostname string
+}
+
+type NetworkResult struct {
+	Target    Target
+	Addresses []net.IP
+	Latency   time.Duration
+	DNSError  error
+	DialError error
+}
+
+// Redirected checks whether any resolved address points to a local/private network, indicating that DNS resolution is
+// redirected (e.g. via the hosts file or a local emulator) rather than pointing at the actual service
+func (r NetworkResult) Redirected() bool {
+	for _, ip := range r.Addresses {
+		if ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() {
+			return true
+		}
+	}
+
+	return false
+}
+
+// CheckNetwork resolves the gpcm hostname of every target and attempts to connect to it, all targets are checked in parallel
+func CheckNetwork(targets []Target) []NetworkResult {
+	results := make([]NetworkResult, len(targets))
+	var wg sync.WaitGroup
+	for i, target := range targets {
+		wg.Add(1)
+		go func(i int, target Target) {
+			defer wg.Done()
+			results[i] = checkTarget(target)
+		}(i, target)
+	}
+	wg.Wait()
+
+	return results
+}
+
+func checkTarget(target Target) NetworkResult {
+	result := NetworkResult{
+		Target: target,
+	}
+
+	host := fmt.Sprintf("gpcm.%s", target.Hostname)
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	addrs, err := net.DefaultResolver.LookupIPAddr(ctx, host)
+	if err != nil {
+		result.DNSError = err
+		return result
+	}
+
+	for _, addr := range addrs {
+		result.Addresses = append(result.Addresses, addr.IP)
+	}
+
+	start := time.Now()
+	conn, err := net.DialTimeout("tcp", net.JoinHostPort(result.Addresses[0].String(), fmt.Sprint(gpcmPort)), timeout)
+	if err != nil {
+		result.DialError = err
+		return result
+	}
+	result.Latency = time.Since(start)
+	_ = conn.Close()
+
+	return result
+}
+
+// FormatNetworkResults renders the results as a plain-text table
+func FormatNetworkResults(results []NetworkResult) string {
+	var sb strings.Builder
+	w := tabwriter.NewWriter(&sb, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(w, "Provider\tHostname\tAddress\tgpcm\tNotes")
+	for _, r := range results {
+		address := "-"
+		if len(r.Addresses) > 0 {
+			address = r.Addresses[0].String()
+		}
+
+		gpcm := "-"
+		var notes []string
+		switch {
+		case r.DNSError != nil:
+			notes = append(notes, "DNS lookup failed")
+		case r.DialError != nil:
+			gpcm = "unreachable"
+		default:
+			gpcm = r.Latency.Round(time.Millisecond).String()
+		}
+
+		if r.Redirected() {
+			notes = append(notes, "resolves to local/private address (hosts file or local emulator?)")
+		}
+
+		_, _ = fmt.Fprintf(w, "%s\tgpcm.%s\t%s\t%s\t%s\n", r.Target.Name, r.Target.Hostname, address, gpcm, strings.Join(notes, ", "))
+	}
+	_ = w.Flush()
+
+	return sb.String()
+}
@@ -0,0 +1,122 @@
+// Package ensure combines migrating all profiles and patching the game into a single "ensure OpenSpy" flow
+package ensure
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/cetteup/conman/pkg/game"
+
+	"github.com/cetteup/bf2-migrator/cmd/bf2-migrator/internal/migrate"
+	"github.com/cetteup/bf2-migrator/cmd/bf2-migrator/internal/patch"
+)
+
+// DryRun describes everything ensuring OpenSpy would do, without making any changes
+func DryRun(t migrate.Title, h game.Handler, dir string) (string, error) {
+	profiles, _, err := t.GetProfiles(h)
+	if err != nil {
+		return "", fmt.Errorf("failed to load list of available profiles: %w", err)
+	}
+
+	var sb strings.Builder
+	sb.WriteString("Profiles\n")
+	for _, profile := range profiles {
+		// Singleplayer profiles are skipped silently, same as when actually migrating
+		if profile.Type != game.ProfileTypeMultiplayer {
+			continue
+		}
+		fmt.Fprintf(&sb, "  %s\n", t.PlanProfile(h, profile))
+	}
+
+	sb.WriteString("\nBinary\n")
+	if dir == "" {
+		sb.WriteString("  Game installation folder not set, binary would not be patched\n")
+		return sb.String(), nil
+	}
+
+	plan, err := patch.PlanPatch(dir, patch.OpenSpy)
+	if err != nil {
+		fmt.Fprintf(&sb, "  Binary cannot be patched (%s)\n", err)
+	} else {
+		for _, line := range strings.Split(plan.Summary(), "\n") {
+			fmt.Fprintf(&sb, "  %s\n", line)
+		}
+	}
+
+	return sb.String(), nil
+}
+
+// Result describes the outcome of each step of ensuring OpenSpy
+type Result struct {
+	Profiles []migrate.ProfileResult
+	// Binary describes the outcome of patching the binary (empty if it was not attempted)
+	Binary string
+	// MayRepatch is true if BF2Hub is installed but its settings were not modified while preparing to patch
+	MayRepatch bool
+}
+
+func (r Result) String() string {
+	var sb strings.Builder
+	for _, p := range r.Profiles {
+		fmt.Fprintf(&sb, "%s\n", p)
+	}
+	if r.Binary != "" {
+		fmt.Fprintf(&sb, "%s: %s\n", patch.BF2ExecutableName, r.Binary)
+	}
+
+	return sb.String()
+}
+
+// EligibleProfiles returns all profiles which would be migrated when ensuring OpenSpy
+func EligibleProfiles(t migrate.Title, h game.Handler) ([]game.Profile, error) {
+	profiles, _, err := t.GetProfiles(h)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load list of available profiles: %w", err)
+	}
+
+	eligible := make([]game.Profile, 0, len(profiles))
+	for _, profile := range profiles {
+		if profile.Type == game.ProfileTypeMultiplayer {
+			eligible = append(eligible, profile)
+		}
+	}
+
+	return eligible, nil
+}
+
+// PrepareFunc prepares for patching the binary (see patch.PrepareForPatch), returning whether BF2Hub may re-patch it
+type PrepareFunc func() (bool, error)
+
+// Run migrates all eligible profiles (pausing for the given delay between profiles) and patches the binary to use
+// OpenSpy, using prepare to prepare for patching, returning the results of each step
+func Run(ctx context.Context, t migrate.Title, h game.Handler, c migrate.Client, prepare PrepareFunc, dir string, delay time.Duration) (Result, error) {
+	eligible, err := EligibleProfiles(t, h)
+	if err != nil {
+		return Result{}, err
+	}
+
+	var result Result
+	result.Profiles, err = t.MigrateProfiles(ctx, h, c, eligible, delay)
+	if err != nil {
+		return result, err
+	}
+	failed := len(migrate.FailedProfiles(result.Profiles)) > 0
+
+	if result.MayRepatch, err = prepare(); err != nil {
+		result.Binary = fmt.Sprintf("failed to prepare for patching (%s)", err)
+		failed = true
+	} else if _, err = patch.PatchBinary(dir, patch.OpenSpy); err != nil {
+		result.Binary = fmt.Sprintf("failed to patch (%s)", err)
+		failed = true
+	} else {
+		result.Binary = fmt.Sprintf("uses %s", patch.OpenSpy.DisplayName)
+	}
+
+	if failed {
+		return result, fmt.Errorf("not all steps succeeded")
+	}
+
+	return result, nil
+}
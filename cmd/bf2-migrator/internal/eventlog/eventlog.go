@@ -0,0 +1,60 @@
+// Package eventlog provides a zerolog writer sending log events to the Windows Event Log, allowing admins to monitor
+// managed deployments using standard Windows tooling
+package eventlog
+
+import (
+	"github.com/rs/zerolog"
+	"golang.org/x/sys/windows/svc/eventlog"
+)
+
+const (
+	// Source is the event source name used for all events
+	Source = "bf2-migrator"
+
+	eventIDInfo    = 1
+	eventIDWarning = 2
+	eventIDError   = 3
+)
+
+// Writer writes (JSON-encoded) zerolog events of level info and above to the Windows Event Log
+type Writer struct {
+	l *eventlog.Log
+}
+
+var _ zerolog.LevelWriter = (*Writer)(nil)
+
+func New(source string) (*Writer, error) {
+	l, err := eventlog.Open(source)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Writer{l: l}, nil
+}
+
+func (w *Writer) Write(p []byte) (int, error) {
+	return w.WriteLevel(zerolog.NoLevel, p)
+}
+
+func (w *Writer) WriteLevel(level zerolog.Level, p []byte) (int, error) {
+	var err error
+	switch level {
+	case zerolog.InfoLevel, zerolog.NoLevel:
+		err = w.l.Info(eventIDInfo, string(p))
+	case zerolog.WarnLevel:
+		err = w.l.Warning(eventIDWarning, string(p))
+	case zerolog.ErrorLevel, zerolog.FatalLevel, zerolog.PanicLevel:
+		err = w.l.Error(eventIDError, string(p))
+	default:
+		// Debug/trace events are too noisy for the event log
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	return len(p), nil
+}
+
+func (w *Writer) Close() error {
+	return w.l.Close()
+}
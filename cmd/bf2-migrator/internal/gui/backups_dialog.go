@@ -0,0 +1,239 @@
+package gui
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/lxn/walk"
+	"github.com/lxn/walk/declarative"
+	"github.com/lxn/win"
+
+	"github.com/cetteup/bf2-migrator/cmd/bf2-migrator/internal/patch"
+)
+
+const (
+	defaultBackupMaxAgeDays = 30
+)
+
+// runBackupsDialog lists all backups in the given dir and allows deleting those older than a chosen number of days
+func runBackupsDialog(owner walk.Form, dir string) error {
+	var dlg *walk.Dialog
+	var backupsTE *walk.TextEdit
+	var daysNE *walk.NumberEdit
+	var closePB *walk.PushButton
+
+	refresh := func() error {
+		backups, err := patch.ListBackups(dir)
+		if err != nil {
+			return err
+		}
+		return backupsTE.SetText(formatBackups(backups))
+	}
+
+	if err := (declarative.Dialog{
+		AssignTo:      &dlg,
+		Title:         "Clean up backups",
+		DefaultButton: &closePB,
+		CancelButton:  &closePB,
+		MinSize:       declarative.Size{Width: 600, Height: 300},
+		Layout:        declarative.VBox{},
+		Children: []declarative.Widget{
+			declarative.TextEdit{
+				AssignTo: &backupsTE,
+				ReadOnly: true,
+				VScroll:  true,
+				HScroll:  true,
+				Font:     declarative.Font{Family: "Consolas", PointSize: 9},
+			},
+			declarative.Composite{
+				Layout: declarative.HBox{
+					MarginsZero: true,
+				},
+				Children: []declarative.Widget{
+					declarative.Label{
+						Text: "Delete backups older than",
+					},
+					declarative.NumberEdit{
+						AssignTo: &daysNE,
+						Value:    float64(defaultBackupMaxAgeDays),
+						MinValue: 0,
+						MaxValue: 3650,
+						Suffix:   " days",
+					},
+					declarative.PushButton{
+						Text: "Delete",
+						OnClicked: func() {
+							days := int(daysNE.Value())
+							backups, err := patch.ListBackups(dir)
+							if err != nil {
+								walk.MsgBox(dlg, "Error", fmt.Sprintf("Failed to list backups: %s", err.Error()), walk.MsgBoxIconError)
+								return
+							}
+
+							disposable, err := patch.DisposableBackups(dir, backups, time.Duration(days)*24*time.Hour, time.Now())
+							if err != nil {
+								walk.MsgBox(dlg, "Error", fmt.Sprintf("Failed to determine which backups can be deleted: %s", err.Error()), walk.MsgBoxIconError)
+								return
+							}
+
+							if len(disposable) == 0 {
+								walk.MsgBox(dlg, "Clean up backups", fmt.Sprintf("No backups older than %d days can be deleted (the latest usable backup is always kept)", days), walk.MsgBoxIconInformation)
+								return
+							}
+
+							confirmed := walk.MsgBox(
+								dlg,
+								"Clean up backups",
+								fmt.Sprintf("Delete the following backups?\n\n%s", formatBackups(disposable)),
+								walk.MsgBoxYesNo|walk.MsgBoxIconQuestion,
+							)
+							if confirmed != win.IDYES {
+								return
+							}
+
+							deleted, err := patch.DeleteBackups(disposable)
+							if err != nil {
+								walk.MsgBox(dlg, "Error", fmt.Sprintf("Failed to delete backups: %s", err.Error()), walk.MsgBoxIconError)
+							} else if len(deleted) < len(disposable) {
+								walk.MsgBox(dlg, "Warning", fmt.Sprintf("Deleted %d of %d backups, the others were modified after being listed", len(deleted), len(disposable)), walk.MsgBoxIconWarning)
+							}
+
+							if err = refresh(); err != nil {
+								walk.MsgBox(dlg, "Error", fmt.Sprintf("Failed to list backups: %s", err.Error()), walk.MsgBoxIconError)
+							}
+						},
+					},
+					declarative.HSpacer{},
+					declarative.PushButton{
+						AssignTo: &closePB,
+						Text:     "Close",
+						OnClicked: func() {
+							dlg.Accept()
+						},
+					},
+				},
+			},
+		},
+	}).Create(owner); err != nil {
+		return err
+	}
+
+	if err := refresh(); err != nil {
+		return err
+	}
+
+	dlg.Run()
+
+	return nil
+}
+
+// runRestoreBackupDialog lists all backups in the given dir which use a known provider and restores the binary from the
+// chosen one. Returns true if the binary was restored.
+func runRestoreBackupDialog(owner walk.Form, r registryRepository, opts Options, dir string) (bool, error) {
+	var dlg *walk.Dialog
+	var backupCB *walk.ComboBox
+	var restorePB *walk.PushButton
+	var cancelPB *walk.PushButton
+
+	all, err := patch.ListBackups(dir)
+	if err != nil {
+		return false, err
+	}
+
+	backups := make([]patch.Backup, 0, len(all))
+	labels := make([]string, 0, len(all))
+	for _, backup := range all {
+		// Backups without a known provider cannot be restored
+		if backup.Provider.Name == "" {
+			continue
+		}
+		backups = append(backups, backup)
+		labels = append(labels, fmt.Sprintf("%s (%s, %s)", backup.Provider.DisplayName, backup.ModTime.Format("2006-01-02 15:04"), filepath.Base(backup.Path)))
+	}
+
+	if len(backups) == 0 {
+		walk.MsgBox(owner, "Restore backup", fmt.Sprintf("No backups of %s using a known provider found in %s", patch.BF2ExecutableName, dir), walk.MsgBoxIconInformation)
+		return false, nil
+	}
+
+	restored := false
+	if _, err = (declarative.Dialog{
+		AssignTo:      &dlg,
+		Title:         "Restore backup",
+		DefaultButton: &restorePB,
+		CancelButton:  &cancelPB,
+		MinSize:       declarative.Size{Width: 400},
+		Layout:        declarative.VBox{},
+		Children: []declarative.Widget{
+			declarative.Label{
+				Text: "Backups are kept per provider, choose the state to restore",
+			},
+			declarative.ComboBox{
+				AssignTo:     &backupCB,
+				Name:         "Select backup",
+				ToolTipText:  "Select backup",
+				Model:        labels,
+				CurrentIndex: 0,
+			},
+			declarative.Composite{
+				Layout: declarative.HBox{
+					MarginsZero: true,
+				},
+				Children: []declarative.Widget{
+					declarative.HSpacer{},
+					declarative.PushButton{
+						AssignTo: &restorePB,
+						Text:     "Restore",
+						OnClicked: func() {
+							i := backupCB.CurrentIndex()
+							if i < 0 || i >= len(backups) {
+								return
+							}
+							backup := backups[i]
+
+							p, err2 := patch.ImportBackup(r, dir, backup.Path, opts.SkipBF2HubRegistry)
+							if err2 != nil {
+								walk.MsgBox(dlg, "Error", fmt.Sprintf("Failed to restore backup: %s", err2.Error()), walk.MsgBoxIconError)
+								return
+							}
+
+							restored = true
+							walk.MsgBox(dlg, "Success", fmt.Sprintf("Restored %s from %s, it now uses %s", patch.BF2ExecutableName, filepath.Base(backup.Path), p.DisplayName), walk.MsgBoxIconInformation)
+							dlg.Accept()
+						},
+					},
+					declarative.PushButton{
+						AssignTo: &cancelPB,
+						Text:     "Cancel",
+						OnClicked: func() {
+							dlg.Cancel()
+						},
+					},
+				},
+			},
+		},
+	}).Run(owner); err != nil {
+		return false, err
+	}
+
+	return restored, nil
+}
+
+func formatBackups(backups []patch.Backup) string {
+	if len(backups) == 0 {
+		return "No backups found"
+	}
+
+	var sb strings.Builder
+	for _, backup := range backups {
+		provider := backup.Provider.DisplayName
+		if provider == "" {
+			provider = "unknown"
+		}
+		fmt.Fprintf(&sb, "%s  %-24s  %-8s  %8d KB  %s\r\n", backup.ModTime.Format("2006-01-02 15:04"), filepath.Base(backup.Path), provider, backup.Size/1024, backup.Hash[:12])
+	}
+
+	return sb.String()
+}
@@ -0,0 +1,93 @@
+package gui
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/cetteup/conman/pkg/game"
+	"github.com/lxn/walk"
+	"github.com/lxn/walk/declarative"
+
+	"github.com/cetteup/bf2-migrator/cmd/bf2-migrator/internal/ensure"
+	"github.com/cetteup/bf2-migrator/cmd/bf2-migrator/internal/migrate"
+)
+
+// retryFunc re-attempts migrating the given profiles
+type retryFunc func(profiles []game.Profile) ([]migrate.ProfileResult, error)
+
+// runBatchSummaryDialog shows the results of a batch migration, offering to retry any failed profiles. The summary is
+// updated in place with the outcome of each retry.
+func runBatchSummaryDialog(owner walk.Form, title string, result ensure.Result, retry retryFunc) error {
+	var dlg *walk.Dialog
+	var summaryTE *walk.TextEdit
+	var retryPB *walk.PushButton
+	var closePB *walk.PushButton
+
+	formatSummary := func() string {
+		// Multi-line text edits require Windows line breaks
+		return strings.ReplaceAll(result.String(), "\n", "\r\n")
+	}
+
+	_, err := declarative.Dialog{
+		AssignTo:      &dlg,
+		Title:         title,
+		DefaultButton: &closePB,
+		CancelButton:  &closePB,
+		MinSize:       declarative.Size{Width: 600, Height: 300},
+		Layout:        declarative.VBox{},
+		Children: []declarative.Widget{
+			declarative.TextEdit{
+				AssignTo: &summaryTE,
+				Text:     formatSummary(),
+				ReadOnly: true,
+				VScroll:  true,
+				HScroll:  true,
+				Font:     declarative.Font{Family: "Consolas", PointSize: 9},
+			},
+			declarative.Composite{
+				Layout: declarative.HBox{
+					MarginsZero: true,
+				},
+				Children: []declarative.Widget{
+					declarative.PushButton{
+						AssignTo: &retryPB,
+						Text:     "Retry failed",
+						Enabled:  len(migrate.FailedProfiles(result.Profiles)) > 0,
+						OnClicked: func() {
+							failed := migrate.FailedProfiles(result.Profiles)
+
+							// Block any actions (including closing the dialog) while retrying
+							dlg.SetEnabled(false)
+							_ = retryPB.SetText("Retrying...")
+
+							go func() {
+								retried, err := retry(failed)
+								dlg.Synchronize(func() {
+									result.Profiles = migrate.MergeResults(result.Profiles, retried)
+									_ = summaryTE.SetText(formatSummary())
+									_ = retryPB.SetText("Retry failed")
+									retryPB.SetEnabled(len(migrate.FailedProfiles(result.Profiles)) > 0)
+									dlg.SetEnabled(true)
+
+									if err != nil {
+										walk.MsgBox(dlg, "Error", fmt.Sprintf("Failed to retry all profiles: %s", err.Error()), walk.MsgBoxIconError)
+									}
+								})
+							}()
+						},
+					},
+					declarative.HSpacer{},
+					declarative.PushButton{
+						AssignTo: &closePB,
+						Text:     "Close",
+						OnClicked: func() {
+							dlg.Accept()
+						},
+					},
+				},
+			},
+		},
+	}.Run(owner)
+
+	return err
+}
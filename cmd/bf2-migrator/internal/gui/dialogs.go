@@ -0,0 +1,245 @@
+package gui
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/lxn/walk"
+	"github.com/lxn/walk/declarative"
+	"github.com/lxn/win"
+	"github.com/rs/zerolog/log"
+
+	"github.com/cetteup/bf2-migrator/cmd/bf2-migrator/internal/diagnostics"
+	"github.com/cetteup/bf2-migrator/cmd/bf2-migrator/internal/patch"
+)
+
+// isRecoverable checks whether a patching error was caused by the binary being in a state the user can recover from
+func isRecoverable(err error) bool {
+	return errors.Is(err, patch.ErrPartialPlayBF2Patch) || errors.Is(err, patch.ErrUnknownModifications)
+}
+
+// runRecoveryDialog offers ways to get a binary which cannot be patched normally back to a known state
+func runRecoveryDialog(owner walk.Form, r registryRepository, opts Options, dir string, cause error) error {
+	var dlg *walk.Dialog
+	var cancelPB *walk.PushButton
+
+	_, err := declarative.Dialog{
+		AssignTo:     &dlg,
+		Title:        "Recover " + patch.BF2ExecutableName,
+		CancelButton: &cancelPB,
+		MinSize:      declarative.Size{Width: 320},
+		Layout:       declarative.VBox{},
+		Children: []declarative.Widget{
+			declarative.Label{
+				Text: fmt.Sprintf("%s cannot be patched in its current state:\n%s\n\nHow would you like to proceed?", patch.BF2ExecutableName, cause.Error()),
+			},
+			declarative.PushButton{
+				Text:    "Restore from backup",
+				Enabled: patch.HasBackup(dir),
+				OnClicked: func() {
+					if err := patch.RestoreBackup(r, dir, opts.SkipBF2HubRegistry); err != nil {
+						walk.MsgBox(dlg, "Error", fmt.Sprintf("Failed to restore %s from backup: %s", patch.BF2ExecutableName, err.Error()), walk.MsgBoxIconError)
+						return
+					}
+
+					walk.MsgBox(dlg, "Success", fmt.Sprintf("Restored %s from backup", patch.BF2ExecutableName), walk.MsgBoxIconInformation)
+					dlg.Accept()
+				},
+			},
+			declarative.PushButton{
+				Text: "Import backup file...",
+				OnClicked: func() {
+					if importBackup(dlg, r, opts, dir) {
+						dlg.Accept()
+					}
+				},
+			},
+			declarative.PushButton{
+				Text: fmt.Sprintf("Force revert to %s", patch.GameSpy.DisplayName),
+				OnClicked: func() {
+					confirmed := walk.MsgBox(
+						dlg,
+						"Warning",
+						fmt.Sprintf("This will replace every known provider-specific value in %s with the %s value. Unknown modifications cannot be reverted this way.\n\nContinue?", patch.BF2ExecutableName, patch.GameSpy.DisplayName),
+						walk.MsgBoxYesNo|walk.MsgBoxIconWarning,
+					)
+					if confirmed != win.IDYES {
+						return
+					}
+
+					backupPath, err := patch.RecoverToGameSpy(r, dir, opts.SkipBF2HubRegistry)
+					if err != nil {
+						walk.MsgBox(dlg, "Error", fmt.Sprintf("Failed to recover %s: %s", patch.BF2ExecutableName, err.Error()), walk.MsgBoxIconError)
+						return
+					}
+
+					walk.MsgBox(dlg, "Success", fmt.Sprintf("Recovered %s to use %s, backup at %s", patch.BF2ExecutableName, patch.GameSpy.DisplayName, backupPath), walk.MsgBoxIconInformation)
+					dlg.Accept()
+				},
+			},
+			declarative.PushButton{
+				Text: "View diagnostics",
+				OnClicked: func() {
+					b, err := os.ReadFile(filepath.Join(dir, patch.BF2ExecutableName))
+					if err != nil {
+						walk.MsgBox(dlg, "Error", fmt.Sprintf("Failed to read %s: %s", patch.BF2ExecutableName, err.Error()), walk.MsgBoxIconError)
+						return
+					}
+
+					walk.MsgBox(dlg, "Diagnostics", "Known values found in binary:\n\n"+patch.DumpSlots(b), walk.MsgBoxIconInformation)
+				},
+			},
+			declarative.PushButton{
+				AssignTo: &cancelPB,
+				Text:     "Cancel",
+				OnClicked: func() {
+					dlg.Cancel()
+				},
+			},
+		},
+	}.Run(owner)
+	return err
+}
+
+// importBackup lets the user pick a backup (e.g. one copied from another machine) and restores the binary from it.
+// Returns true if the binary was restored.
+func importBackup(owner walk.Form, r registryRepository, opts Options, dir string) bool {
+	dlg := &walk.FileDialog{
+		Title:  "Choose backup to import",
+		Filter: "Backups (*.bak)|*.bak|Executables (*.exe)|*.exe|All files (*.*)|*.*",
+	}
+
+	ok, err := dlg.ShowOpen(owner)
+	if err != nil {
+		walk.MsgBox(owner, "Error", fmt.Sprintf("Failed to choose backup: %s", err.Error()), walk.MsgBoxIconError)
+		return false
+	} else if !ok {
+		// User canceled dialog
+		return false
+	}
+
+	confirmed := walk.MsgBox(
+		owner,
+		"Import backup",
+		fmt.Sprintf("This will replace %s in %s with %s.\n\nContinue?", patch.BF2ExecutableName, dir, filepath.Base(dlg.FilePath)),
+		walk.MsgBoxYesNo|walk.MsgBoxIconQuestion,
+	)
+	if confirmed != win.IDYES {
+		return false
+	}
+
+	p, err := patch.ImportBackup(r, dir, dlg.FilePath, opts.SkipBF2HubRegistry)
+	if err != nil {
+		walk.MsgBox(owner, "Error", fmt.Sprintf("Failed to import backup: %s", err.Error()), walk.MsgBoxIconError)
+		return false
+	}
+
+	walk.MsgBox(owner, "Success", fmt.Sprintf("Restored %s from %s, it now uses %s", patch.BF2ExecutableName, filepath.Base(dlg.FilePath), p.DisplayName), walk.MsgBoxIconInformation)
+	return true
+}
+
+// confirmPlan shows what patching would change and asks the user to confirm. Returns true if patching should proceed,
+// which is also the case if no plan could be determined (patching will then fail with an appropriate error).
+func confirmPlan(owner walk.Form, dir string, p patch.Provider) bool {
+	plan, err := patch.PlanPatch(dir, p)
+	if err != nil || plan.NoOp() {
+		return true
+	}
+
+	if !plan.Valid() {
+		walk.MsgBox(owner, "Error", fmt.Sprintf("%s\n\nCannot patch a binary with unexpected values, revert changes first", plan.Summary()), walk.MsgBoxIconError)
+		return false
+	}
+
+	return walk.MsgBox(owner, "Confirm patch", plan.Summary()+"\n\nApply patch?", walk.MsgBoxOKCancel|walk.MsgBoxIconQuestion) == win.IDOK
+}
+
+// confirmRunningLaunchers warns about running launchers which might relaunch the game while patching, returning whether
+// the user wants to continue anyway
+func confirmRunningLaunchers(owner walk.Form) bool {
+	launchers, err := patch.DetectRunningLaunchers()
+	if err != nil {
+		// Only a warning, so don't block patching if launchers cannot be detected
+		log.Warn().
+			Err(err).
+			Msg("Failed to detect running launchers")
+		return true
+	}
+
+	if len(launchers) == 0 {
+		return true
+	}
+
+	confirmed := walk.MsgBox(
+		owner,
+		"Warning",
+		fmt.Sprintf("The following launchers are running and may relaunch the game while patching: %s\n\nPlease close them before continuing. Continue anyway?", strings.Join(launchers, ", ")),
+		walk.MsgBoxYesNo|walk.MsgBoxIconWarning,
+	)
+
+	return confirmed == win.IDYES
+}
+
+// confirmLocalEmulator warns about a local GameSpy emulator which may interfere with the given provider, returning
+// whether the user wants to continue anyway
+func confirmLocalEmulator(owner walk.Form, p patch.Provider) bool {
+	warning := diagnostics.FormatLocalListeners(diagnostics.CheckLocalListeners(), p.DisplayName)
+	if warning == "" {
+		return true
+	}
+
+	return walk.MsgBox(owner, "Warning", warning+"\n\nContinue anyway?", walk.MsgBoxYesNo|walk.MsgBoxIconWarning) == win.IDYES
+}
+
+// runPatchSuccessDialog shows the result of patching and offers to launch the game right away (unless the binary is in
+// an unknown state)
+func runPatchSuccessDialog(owner walk.Form, dir string, message string) error {
+	var dlg *walk.Dialog
+	var okPB *walk.PushButton
+
+	_, detectErr := patch.DetectProvider(dir)
+
+	_, err := declarative.Dialog{
+		AssignTo:      &dlg,
+		Title:         "Success",
+		DefaultButton: &okPB,
+		CancelButton:  &okPB,
+		MinSize:       declarative.Size{Width: 320},
+		Layout:        declarative.VBox{},
+		Children: []declarative.Widget{
+			declarative.Label{
+				Text: message,
+			},
+			declarative.Composite{
+				Layout: declarative.HBox{
+					MarginsZero: true,
+				},
+				Children: []declarative.Widget{
+					declarative.HSpacer{},
+					declarative.PushButton{
+						Text:    "Launch BF2 now",
+						Enabled: detectErr == nil,
+						OnClicked: func() {
+							if err := patch.LaunchGame(dir); err != nil {
+								walk.MsgBox(dlg, "Error", fmt.Sprintf("Failed to launch %s: %s", patch.BF2ExecutableName, err.Error()), walk.MsgBoxIconError)
+								return
+							}
+							dlg.Accept()
+						},
+					},
+					declarative.PushButton{
+						AssignTo: &okPB,
+						Text:     "OK",
+						OnClicked: func() {
+							dlg.Accept()
+						},
+					},
+				},
+			},
+		},
+	}.Run(owner)
+	return err
+}
@@ -0,0 +1,85 @@
+package gui
+
+import (
+	"fmt"
+
+	"github.com/lxn/walk"
+	"github.com/lxn/walk/declarative"
+	"github.com/rs/zerolog/log"
+
+	"github.com/cetteup/bf2-migrator/cmd/bf2-migrator/internal/patch"
+)
+
+// createFallbackWindow creates a bare-bones window offering only the core patch/revert actions, for use if the full
+// main window cannot be created
+func createFallbackWindow(opts Options, f finder, r registryRepository) (*walk.MainWindow, error) {
+	var mw *walk.MainWindow
+	var pathLE *walk.LineEdit
+
+	patchTo := func(p patch.Provider) {
+		dir := pathLE.Text()
+		if dir == "" {
+			walk.MsgBox(mw, "Warning", "Please enter the game installation folder first", walk.MsgBoxIconWarning)
+			return
+		}
+
+		// Block any actions during patching
+		mw.SetEnabled(false)
+		defer mw.SetEnabled(true)
+
+		if _, err := patch.PrepareForPatch(r, opts.SkipBF2HubRegistry); err != nil {
+			walk.MsgBox(mw, "Error", fmt.Sprintf("Failed to prepare for patching %s: %s", patch.BF2ExecutableName, err.Error()), walk.MsgBoxIconError)
+			return
+		}
+
+		result, err := patch.PatchBinary(dir, p)
+		if err != nil {
+			walk.MsgBox(mw, "Error", fmt.Sprintf("Failed to patch %s: %s", patch.BF2ExecutableName, err.Error()), walk.MsgBoxIconError)
+			return
+		}
+
+		walk.MsgBox(mw, "Success", result.String(), walk.MsgBoxIconInformation)
+	}
+
+	if err := (declarative.MainWindow{
+		AssignTo: &mw,
+		Title:    WindowTitle,
+		Name:     WindowTitle,
+		Size:     declarative.Size{Width: windowWidth, Height: 150},
+		Layout:   declarative.VBox{},
+		Children: []declarative.Widget{
+			declarative.Label{
+				Text: "Installation folder",
+			},
+			declarative.LineEdit{
+				AssignTo: &pathLE,
+				Name:     "Installation folder",
+			},
+			declarative.PushButton{
+				Text: fmt.Sprintf("Patch to use %s", patch.OpenSpy.DisplayName),
+				OnClicked: func() {
+					patchTo(patch.OpenSpy)
+				},
+			},
+			declarative.PushButton{
+				Text: fmt.Sprintf("Revert to %s", patch.GameSpy.DisplayName),
+				OnClicked: func() {
+					patchTo(patch.GameSpy)
+				},
+			},
+		},
+	}).Create(); err != nil {
+		return nil, err
+	}
+
+	detected, err := patch.DetectInstallPath(f)
+	if err != nil {
+		log.Error().
+			Err(err).
+			Msg("Failed to detect game installation folder")
+	} else {
+		_ = pathLE.SetText(detected)
+	}
+
+	return mw, nil
+}
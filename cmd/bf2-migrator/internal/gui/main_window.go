@@ -1,83 +1,49 @@
 package gui
 
 import (
-	"bytes"
+	"context"
 	_ "embed"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"os"
-	"path/filepath"
+	"strings"
+	"time"
 
-	"github.com/cetteup/conman/pkg/game/bf2"
 	"github.com/lxn/walk"
 	"github.com/lxn/walk/declarative"
 	"github.com/lxn/win"
-	"github.com/mitchellh/go-ps"
 	"github.com/rs/zerolog/log"
 	"golang.org/x/sys/windows/registry"
 
 	"github.com/cetteup/conman/pkg/game"
 	"github.com/cetteup/joinme.click-launcher/pkg/software_finder"
 
+	"github.com/cetteup/bf2-migrator/cmd/bf2-migrator/internal/config"
+	"github.com/cetteup/bf2-migrator/cmd/bf2-migrator/internal/diagnostics"
+	"github.com/cetteup/bf2-migrator/cmd/bf2-migrator/internal/ensure"
+	"github.com/cetteup/bf2-migrator/cmd/bf2-migrator/internal/migrate"
+	"github.com/cetteup/bf2-migrator/cmd/bf2-migrator/internal/patch"
 	api "github.com/cetteup/bf2-migrator/pkg/openspy"
 )
 
 const (
-	windowWidth  = 290
-	windowHeight = 350
-
-	bf2ExecutableName    = "BF2.exe"
-	bf2hubExecutableName = "bf2hub.exe"
-)
+	WindowTitle = "BF2 migrator"
 
-type provider struct {
-	Name        string
-	Fingerprint fingerprint
-}
+	version = "v0.5.0"
 
-type fingerprint struct {
-	Hostname   []byte
-	HostsPath  []byte
-	Additional [][]byte
-}
+	windowWidth  = 290
+	windowHeight = 390
 
-var bf2hub = provider{
-	Name: "BF2Hub",
-	Fingerprint: fingerprint{
-		// BF2Hub does not modify the hostname, so modify based on the GameSpy hostname
-		Hostname:  []byte("gamespy.com"),
-		HostsPath: []byte("\\drivers\\xtc\\hosts"),
-		Additional: [][]byte{
-			[]byte("bf2hbc.dll"),
-		},
-	},
-}
-var playbf2 = provider{
-	Name: "PlayBF2",
-	Fingerprint: fingerprint{
-		Hostname:  []byte("playbf2.ru"),
-		HostsPath: []byte("\\drivers\\etc\\hasts"),
-	},
-}
-var openspy = provider{
-	Name: "OpenSpy",
-	Fingerprint: fingerprint{
-		Hostname:  []byte("openspy.net"),
-		HostsPath: []byte("\\drivers\\etz\\hosts"),
-	},
-}
-var gamespy = provider{
-	Name: "GameSpy",
-	Fingerprint: fingerprint{
-		Hostname:  []byte("gamespy.com"),
-		HostsPath: []byte("\\drivers\\etc\\hosts"),
-	},
-}
+	// Show profile filter if there are more profiles than this
+	profileFilterThreshold = 10
+	profileFilterHeight    = 25
+)
 
 type client interface {
-	CreateAccount(email, password string, partnerCode int) error
-	CreateProfile(nick string, namespaceID int) error
-	GetProfiles() ([]api.ProfileDTO, error)
+	migrate.Client
+	OnRetry(fn api.RetryFunc)
+	SetContext(ctx context.Context)
 }
 
 type finder interface {
@@ -88,7 +54,14 @@ type registryRepository interface {
 	OpenKey(k registry.Key, path string, access uint32, cb func(key registry.Key) error) error
 }
 
-func CreateMainWindow(h game.Handler, c client, f finder, r registryRepository) (*walk.MainWindow, error) {
+// Options are settings which apply to the current session only, e.g. those passed via command line flags
+type Options struct {
+	// Title is the game whose profiles are migrated
+	Title              migrate.Title
+	SkipBF2HubRegistry bool
+}
+
+func CreateMainWindow(cfg *config.Config, opts Options, h game.Handler, c client, f finder, r registryRepository) (*walk.MainWindow, error) {
 	icon, err := walk.NewIconFromResourceIdWithSize(2, walk.Size{Width: 256, Height: 256})
 	if err != nil {
 		return nil, err
@@ -98,24 +71,308 @@ func CreateMainWindow(h game.Handler, c client, f finder, r registryRepository)
 	screenHeight := win.GetSystemMetrics(win.SM_CYSCREEN)
 
 	var mw *walk.MainWindow
+	var profileFilterLE *walk.LineEdit
 	var profileCB *walk.ComboBox
 	var migratePB *walk.PushButton
 	var pathTE *walk.TextEdit
+	var statusLbl *walk.Label
 	var providerCB *walk.ComboBox
 	var patchPB *walk.PushButton
 	var revertPB *walk.PushButton
+	var manageProfilesA *walk.Action
+	var updatePasswordA *walk.Action
+	var alwaysOnTopA *walk.Action
+	var ownerCheckA *walk.Action
+	var confirmEachChangeA *walk.Action
+	var minimizeToTrayA *walk.Action
+	var reviewProcessesA *walk.Action
+	var trayIcon *walk.NotifyIcon
+
+	migrateButtonText := fmt.Sprintf("Migrate to %s", patch.OpenSpy.DisplayName)
+	// Retries are shown on the migrate button regardless of which action sent the request (see OnRetry below), so every
+	// action using the client needs to reset the button once it is done
+	resetMigrateButton := func() {
+		_ = migratePB.SetText(migrateButtonText)
+	}
+
+	// Cancelled once the window is closed, aborting any requests still in flight
+	ctx, cancel := context.WithCancel(context.Background())
+	c.SetContext(ctx)
+
+	var allProfiles []game.Profile
+	selectedProfile := func() (game.Profile, bool) {
+		profiles, _ := profileCB.Model().([]game.Profile)
+		i := profileCB.CurrentIndex()
+		if i < 0 || i >= len(profiles) {
+			return game.Profile{}, false
+		}
+		return profiles[i], true
+	}
+
+	// updateActionStates enables/disables every profile-dependent action based on whether it can be used with the
+	// given profile (ok being false if no profile is selected)
+	updateActionStates := func(profile game.Profile, ok bool) {
+		// Account actions cannot be used with singleplayer profiles, since those don't have an account/password
+		multiplayer := ok && profile.Type == game.ProfileTypeMultiplayer
+		migratePB.SetEnabled(multiplayer)
+		_ = manageProfilesA.SetEnabled(multiplayer)
+		_ = updatePasswordA.SetEnabled(multiplayer)
+	}
+
+	// updateStatus shows the provider currently used by the game (along with the game version) and emphasizes the
+	// matching action, e.g. revert if the game is currently patched
+	updateStatus := func() {
+		gameVersion := "unknown"
+		if v, err2 := patch.DetectVersion(pathTE.Text()); err2 == nil {
+			gameVersion = v.String()
+		}
+
+		current, err2 := patch.DetectProvider(pathTE.Text())
+		if err2 != nil {
+			_ = statusLbl.SetText(fmt.Sprintf("Current provider: unknown, BF2 version: %s", gameVersion))
+			return
+		}
+		_ = statusLbl.SetText(fmt.Sprintf("Current provider: %s, BF2 version: %s", current.DisplayName, gameVersion))
+
+		if current.Name == patch.GameSpy.Name || current.Name == patch.BF2Hub.Name {
+			_ = patchPB.SetFocus()
+			return
+		}
+
+		// Pre-select the provider in use (if offered), so the patch action would be a no-op
+		for i, p := range providerCB.Model().([]patch.Provider) {
+			if p.Name == current.Name {
+				_ = providerCB.SetCurrentIndex(i)
+				break
+			}
+		}
+		_ = revertPB.SetFocus()
+	}
+
+	// BF2Hub settings from before they were modified while preparing to patch, nil unless they need to be restored
+	var bf2HubSettings *patch.BF2HubSettings
+	rememberBF2HubSettings := func() {
+		if bf2HubSettings == nil && !opts.SkipBF2HubRegistry {
+			settings, installed, err2 := patch.ReadBF2HubSettings(r)
+			if err2 != nil {
+				// Only needed to offer restoring the settings on exit, so don't block patching
+				log.Warn().Err(err2).Msg("Failed to read BF2Hub settings")
+			} else if installed && !settings.Disabled() {
+				bf2HubSettings = &settings
+			}
+		}
+	}
+	prepareForPatch := func() (bool, error) {
+		rememberBF2HubSettings()
+
+		if !cfg.ReviewProcesses {
+			return patch.PrepareForPatch(r, opts.SkipBF2HubRegistry)
+		}
+
+		return patch.PrepareForPatchReviewed(r, opts.SkipBF2HubRegistry, func(processes []patch.BlockingProcess) ([]patch.BlockingProcess, bool) {
+			return reviewProcesses(mw, processes)
+		})
+	}
+
+	// confirmSteps asks to confirm each change patching to the provider would apply (if the user opted in), returning
+	// whether to continue. Needs to be called before preparing for patching, so declining really changes nothing.
+	confirmSteps := func(p patch.Provider) bool {
+		if !cfg.ConfirmEachChange {
+			return true
+		}
+
+		err2 := patch.ConfirmSteps(pathTE.Text(), p, func(s patch.Step) bool {
+			return walk.MsgBox(mw, "Confirm change", s.String()+"\n\nApply?", walk.MsgBoxYesNo|walk.MsgBoxIconQuestion) == win.IDYES
+		})
+		if errors.Is(err2, patch.ErrAborted) {
+			walk.MsgBox(mw, "Aborted", err2.Error(), walk.MsgBoxIconInformation)
+			return false
+		}
+
+		// Any other error is about the binary's state, which patching reports (and offers recovery for) anyway
+		return true
+	}
 
 	enablePatch := func(path string) {
 		_ = pathTE.SetText(path)
 		_ = pathTE.SetToolTipText(path)
 		patchPB.SetEnabled(true)
 		revertPB.SetEnabled(true)
+		updateStatus()
+	}
+
+	// State from before the last patch applied during this session, nil if there is nothing to undo
+	var undoState *patch.UndoState
+	rememberUndo := func(result patch.Result) {
+		undoState = &result.Undo
+		// Persist the state, so the patch can still be undone if the tool exits unexpectedly
+		if err2 := patch.SaveUndoState(result.Undo); err2 != nil {
+			log.Warn().
+				Err(err2).
+				Msg("Failed to save undo state")
+		}
+	}
+
+	detectInstallPath := func() {
+		detected, err2 := patch.DetectInstallPath(f)
+		if err2 != nil {
+			walk.MsgBox(mw, "Warning", "Could not detect game installation folder, please choose the path manually", walk.MsgBoxIconWarning)
+			return
+		}
+
+		enablePatch(detected)
+	}
+
+	applyPatch := func() {
+		// Block any actions during patching
+		mw.SetEnabled(false)
+		_ = patchPB.SetText("Patching...")
+		defer func() {
+			_ = patchPB.SetText("Apply patch")
+			mw.SetEnabled(true)
+			updateStatus()
+		}()
+
+		if !confirmRunningLaunchers(mw) {
+			return
+		}
+
+		// Confirm everything before preparing, since preparing closes processes and modifies BF2Hub's settings
+		p := providerCB.Model().([]patch.Provider)[providerCB.CurrentIndex()]
+		if !confirmPlan(mw, pathTE.Text(), p) || !confirmLocalEmulator(mw, p) || !confirmSteps(p) {
+			return
+		}
+
+		mayRepatch, err2 := prepareForPatch()
+		if errors.Is(err2, patch.ErrAborted) {
+			return
+		} else if err2 != nil {
+			walk.MsgBox(mw, "Error", fmt.Sprintf("Failed to prepare for patching %s: %s", patch.BF2ExecutableName, err2.Error()), walk.MsgBoxIconError)
+			return
+		}
+
+		if mayRepatch {
+			walk.MsgBox(mw, "Warning", fmt.Sprintf("%s is installed and its settings were not modified, it may re-patch %s", patch.BF2Hub.DisplayName, patch.BF2ExecutableName), walk.MsgBoxIconWarning)
+		}
+
+		result, err2 := patch.PatchBinary(pathTE.Text(), p)
+		if isRecoverable(err2) {
+			if err3 := runRecoveryDialog(mw, r, opts, pathTE.Text(), err2); err3 != nil {
+				walk.MsgBox(mw, "Error", fmt.Sprintf("Failed to show recovery options: %s", err3.Error()), walk.MsgBoxIconError)
+			}
+		} else if err2 != nil {
+			log.Error().Err(err2).Str("provider", p.Name).Msg("Failed to patch game")
+			walk.MsgBox(mw, "Error", fmt.Sprintf("Failed to patch %s: %s", patch.BF2ExecutableName, err2.Error()), walk.MsgBoxIconError)
+		} else if result.NoOp {
+			// BF2Hub needs to stay disabled, since it would otherwise undo the patch
+			bf2HubSettings = nil
+			walk.MsgBox(mw, "Success", result.String(), walk.MsgBoxIconInformation)
+		} else {
+			bf2HubSettings = nil
+			rememberUndo(result)
+			log.Info().Str("from", result.From.Name).Str("to", result.To.Name).Msg("Patched game")
+			if err3 := runPatchSuccessDialog(mw, pathTE.Text(), result.String()); err3 != nil {
+				walk.MsgBox(mw, "Success", result.String(), walk.MsgBoxIconInformation)
+			}
+		}
+	}
+
+	revertPatch := func() {
+		// Block any actions during patching
+		mw.SetEnabled(false)
+		_ = revertPB.SetText("Reverting...")
+		defer func() {
+			_ = revertPB.SetText("Revert patch")
+			mw.SetEnabled(true)
+			updateStatus()
+		}()
+
+		if !confirmRunningLaunchers(mw) || !confirmSteps(patch.GameSpy) {
+			return
+		}
+
+		_, err2 := prepareForPatch()
+		if errors.Is(err2, patch.ErrAborted) {
+			return
+		} else if err2 != nil {
+			walk.MsgBox(mw, "Error", fmt.Sprintf("Failed to prepare for reverting %s: %s", patch.BF2ExecutableName, err2.Error()), walk.MsgBoxIconError)
+			return
+		}
+
+		result, err2 := patch.RevertBinaryInteractive(pathTE.Text(), nil)
+		if isRecoverable(err2) {
+			if err3 := runRecoveryDialog(mw, r, opts, pathTE.Text(), err2); err3 != nil {
+				walk.MsgBox(mw, "Error", fmt.Sprintf("Failed to show recovery options: %s", err3.Error()), walk.MsgBoxIconError)
+			}
+		} else if err2 != nil {
+			log.Error().Err(err2).Str("provider", patch.GameSpy.Name).Msg("Failed to revert game")
+			walk.MsgBox(mw, "Error", fmt.Sprintf("Failed to patch %s: %s", patch.BF2ExecutableName, err2.Error()), walk.MsgBoxIconError)
+		} else if result.NoOp {
+			walk.MsgBox(mw, "Success", result.String(), walk.MsgBoxIconInformation)
+		} else {
+			rememberUndo(result)
+			log.Info().Str("from", result.From.Name).Str("to", result.To.Name).Msg("Reverted game")
+			message := result.String()
+			// Only point users to BF2Hub's patcher if they actually came from BF2Hub
+			if result.From.Name == patch.BF2Hub.Name {
+				message += fmt.Sprintf("\n\nYou can now use the %s Patcher again to go back to %s", patch.BF2Hub.DisplayName, patch.BF2Hub.DisplayName)
+			}
+			walk.MsgBox(mw, "Success", message, walk.MsgBoxIconInformation)
+		}
+	}
+
+	// setTrayEnabled adds/removes the tray icon, with the window being minimized to the tray while it is present
+	setTrayEnabled := func(enabled bool) error {
+		if enabled && trayIcon == nil {
+			ni, err2 := createTrayIcon(mw, icon, []trayAction{
+				{
+					Text:        "Detect installation folder",
+					OnTriggered: detectInstallPath,
+				},
+				{
+					Text: fmt.Sprintf("Patch to use %s", patch.OpenSpy.DisplayName),
+					OnTriggered: func() {
+						if pathTE.Text() == "" {
+							walk.MsgBox(mw, "Warning", "Please detect or choose the game installation folder first", walk.MsgBoxIconWarning)
+							return
+						}
+						for i, p := range providerCB.Model().([]patch.Provider) {
+							if p.Name == patch.OpenSpy.Name {
+								_ = providerCB.SetCurrentIndex(i)
+								break
+							}
+						}
+						applyPatch()
+					},
+				},
+				{
+					Text: fmt.Sprintf("Revert to %s", patch.GameSpy.DisplayName),
+					OnTriggered: func() {
+						if pathTE.Text() == "" {
+							walk.MsgBox(mw, "Warning", "Please detect or choose the game installation folder first", walk.MsgBoxIconWarning)
+							return
+						}
+						revertPatch()
+					},
+				},
+			})
+			if err2 != nil {
+				return err2
+			}
+			trayIcon = ni
+		} else if !enabled && trayIcon != nil {
+			_ = trayIcon.Dispose()
+			trayIcon = nil
+		}
+
+		setMinimizable(mw.Handle(), trayIcon != nil)
+		return nil
 	}
 
 	if err = (declarative.MainWindow{
 		AssignTo: &mw,
-		Title:    "BF2 migrator",
-		Name:     "BF2 migrator",
+		Title:    WindowTitle,
+		Name:     WindowTitle,
 		Bounds: declarative.Rectangle{
 			X:      int((screenWidth - windowWidth) / 2),
 			Y:      int((screenHeight - windowHeight) / 2),
@@ -125,6 +382,606 @@ func CreateMainWindow(h game.Handler, c client, f finder, r registryRepository)
 		Layout:  declarative.VBox{},
 		Icon:    icon,
 		ToolBar: declarative.ToolBar{},
+		MenuItems: []declarative.MenuItem{
+			declarative.Menu{
+				Text: "&Options",
+				Items: []declarative.MenuItem{
+					declarative.Action{
+						AssignTo:  &alwaysOnTopA,
+						Text:      "Always on top",
+						Checkable: true,
+						Checked:   cfg.AlwaysOnTop,
+						OnTriggered: func() {
+							cfg.AlwaysOnTop = alwaysOnTopA.Checked()
+							setAlwaysOnTop(mw.Handle(), cfg.AlwaysOnTop)
+							if err2 := cfg.Save(); err2 != nil {
+								log.Error().
+									Err(err2).
+									Msg("Failed to save config")
+							}
+						},
+					},
+					declarative.Action{
+						AssignTo:  &ownerCheckA,
+						Text:      "Warn about other users' profiles",
+						Checkable: true,
+						Checked:   !cfg.SkipProfileOwnerCheck,
+						OnTriggered: func() {
+							cfg.SkipProfileOwnerCheck = !ownerCheckA.Checked()
+							if err2 := cfg.Save(); err2 != nil {
+								log.Error().
+									Err(err2).
+									Msg("Failed to save config")
+							}
+						},
+					},
+					declarative.Action{
+						AssignTo:  &confirmEachChangeA,
+						Text:      "Confirm each change when patching",
+						Checkable: true,
+						Checked:   cfg.ConfirmEachChange,
+						OnTriggered: func() {
+							cfg.ConfirmEachChange = confirmEachChangeA.Checked()
+							if err2 := cfg.Save(); err2 != nil {
+								log.Error().
+									Err(err2).
+									Msg("Failed to save config")
+							}
+						},
+					},
+					declarative.Action{
+						AssignTo:  &reviewProcessesA,
+						Text:      "Review processes before closing them",
+						Checkable: true,
+						Checked:   cfg.ReviewProcesses,
+						OnTriggered: func() {
+							cfg.ReviewProcesses = reviewProcessesA.Checked()
+							if err2 := cfg.Save(); err2 != nil {
+								log.Error().
+									Err(err2).
+									Msg("Failed to save config")
+							}
+						},
+					},
+					declarative.Action{
+						AssignTo:  &minimizeToTrayA,
+						Text:      "Minimize to system tray",
+						Checkable: true,
+						Checked:   cfg.MinimizeToTray,
+						OnTriggered: func() {
+							if err2 := setTrayEnabled(minimizeToTrayA.Checked()); err2 != nil {
+								walk.MsgBox(mw, "Error", fmt.Sprintf("Failed to add system tray icon: %s", err2.Error()), walk.MsgBoxIconError)
+								_ = minimizeToTrayA.SetChecked(false)
+								return
+							}
+
+							cfg.MinimizeToTray = minimizeToTrayA.Checked()
+							if err2 := cfg.Save(); err2 != nil {
+								log.Error().
+									Err(err2).
+									Msg("Failed to save config")
+							}
+						},
+					},
+				},
+			},
+			declarative.Menu{
+				Text: "&Tools",
+				Items: []declarative.MenuItem{
+					declarative.Action{
+						Text: fmt.Sprintf("Ensure %s (migrate all and patch)...", patch.OpenSpy.DisplayName),
+						OnTriggered: func() {
+							dir := pathTE.Text()
+							if dir == "" {
+								walk.MsgBox(mw, "Warning", "Please detect or choose the game installation folder first", walk.MsgBoxIconWarning)
+								return
+							}
+
+							report, err2 := ensure.DryRun(opts.Title, h, dir)
+							if err2 != nil {
+								walk.MsgBox(mw, "Error", fmt.Sprintf("Failed to determine required changes: %s", err2.Error()), walk.MsgBoxIconError)
+								return
+							}
+
+							if err2 = runTextDialog(mw, fmt.Sprintf("Ensure %s (dry run)", patch.OpenSpy.DisplayName), report); err2 != nil {
+								walk.MsgBox(mw, "Error", fmt.Sprintf("Failed to show required changes: %s", err2.Error()), walk.MsgBoxIconError)
+								return
+							}
+
+							eligible, err2 := ensure.EligibleProfiles(opts.Title, h)
+							if err2 != nil {
+								walk.MsgBox(mw, "Error", err2.Error(), walk.MsgBoxIconError)
+								return
+							}
+
+							// Use a conservative default until the first batch migration recorded actual timings
+							perProfile := migrate.DefaultProfileDuration
+							if cfg.ProfileMigrationMillis > 0 {
+								perProfile = time.Duration(cfg.ProfileMigrationMillis) * time.Millisecond
+							}
+							estimate := migrate.EstimateBatch(len(eligible), perProfile, migrate.DefaultBatchDelay)
+
+							confirmed := walk.MsgBox(
+								mw,
+								fmt.Sprintf("Ensure %s", patch.OpenSpy.DisplayName),
+								fmt.Sprintf("Migrating %d profiles, %s\n\nApply the changes listed in the dry run?", len(eligible), migrate.FormatEstimate(estimate)),
+								walk.MsgBoxYesNo|walk.MsgBoxIconQuestion,
+							)
+							if confirmed != win.IDYES {
+								return
+							}
+
+							mw.SetEnabled(false)
+							go func() {
+								// Prepare the same way as patching directly, which needs the UI thread to remember the BF2Hub
+								// settings and to let the user review the processes about to be closed
+								prepare := func() (bool, error) {
+									var mayRepatch bool
+									var err4 error
+									done := make(chan struct{})
+									mw.Synchronize(func() {
+										mayRepatch, err4 = prepareForPatch()
+										// Closing the review dialog enables its owner again, but patching is not done yet
+										mw.SetEnabled(false)
+										close(done)
+									})
+									<-done
+
+									return mayRepatch, err4
+								}
+								result, err3 := ensure.Run(ctx, opts.Title, h, c, prepare, dir, migrate.DefaultBatchDelay)
+								mw.Synchronize(func() {
+									resetMigrateButton()
+									mw.SetEnabled(true)
+									updateStatus()
+									if err3 != nil && len(result.Profiles) == 0 && result.Binary == "" {
+										walk.MsgBox(mw, "Error", fmt.Sprintf("Failed to ensure %s: %s", patch.OpenSpy.DisplayName, err3.Error()), walk.MsgBoxIconError)
+										return
+									}
+
+									// Record timings to base future estimates on
+									if avg, ok := migrate.AverageDuration(result.Profiles); ok {
+										cfg.ProfileMigrationMillis = avg.Milliseconds()
+										if err4 := cfg.Save(); err4 != nil {
+											log.Error().
+												Err(err4).
+												Msg("Failed to save config")
+										}
+									}
+
+									retry := func(profiles []game.Profile) ([]migrate.ProfileResult, error) {
+										return opts.Title.MigrateProfiles(ctx, h, c, profiles, migrate.DefaultBatchDelay)
+									}
+									err4 := runBatchSummaryDialog(mw, fmt.Sprintf("Ensure %s", patch.OpenSpy.DisplayName), result, retry)
+									// Retrying failed profiles from the summary may have shown retries as well
+									resetMigrateButton()
+									if err4 != nil {
+										walk.MsgBox(mw, "Error", fmt.Sprintf("Failed to show results: %s", err4.Error()), walk.MsgBoxIconError)
+									}
+
+									if result.MayRepatch {
+										walk.MsgBox(mw, "Warning", fmt.Sprintf("%s is installed and its settings were not modified, it may re-patch %s", patch.BF2Hub.DisplayName, patch.BF2ExecutableName), walk.MsgBoxIconWarning)
+									}
+								})
+							}()
+						},
+					},
+					declarative.Action{
+						Text: "Check connectivity",
+						OnTriggered: func() {
+							selected := providerCB.Model().([]patch.Provider)[providerCB.CurrentIndex()]
+							mw.SetEnabled(false)
+							go func() {
+								results := diagnostics.CheckNetwork(networkTargets())
+								local := diagnostics.CheckLocalListeners()
+								mw.Synchronize(func() {
+									mw.SetEnabled(true)
+									report := diagnostics.FormatNetworkResults(results)
+									if warning := diagnostics.FormatLocalListeners(local, selected.DisplayName); warning != "" {
+										report += "\nWarning: " + warning + "\n"
+									}
+									if err2 := runTextDialog(mw, "Connectivity", report); err2 != nil {
+										walk.MsgBox(mw, "Error", fmt.Sprintf("Failed to show connectivity results: %s", err2.Error()), walk.MsgBoxIconError)
+									}
+								})
+							}()
+						},
+					},
+					declarative.Action{
+						AssignTo: &manageProfilesA,
+						Text:     fmt.Sprintf("Manage %s profiles...", patch.OpenSpy.DisplayName),
+						OnTriggered: func() {
+							profile, ok := selectedProfile()
+							if !ok || profile.Type != game.ProfileTypeMultiplayer {
+								walk.MsgBox(mw, "Warning", fmt.Sprintf("Only multiplayer profiles have an %s account", patch.OpenSpy.DisplayName), walk.MsgBoxIconWarning)
+								return
+							}
+
+							// Log in in the background to keep the window responsive (and able to show retry progress)
+							mw.SetEnabled(false)
+							go func() {
+								_, err2 := opts.Title.Authenticate(h, c, profile)
+								mw.Synchronize(func() {
+									mw.SetEnabled(true)
+									resetMigrateButton()
+									if err2 != nil {
+										walk.MsgBox(mw, "Error", fmt.Sprintf("Failed to log in to %s account of %q: %s", patch.OpenSpy.DisplayName, profile.Name, err2.Error()), walk.MsgBoxIconError)
+										return
+									}
+
+									err2 = runProfilesDialog(mw, opts.Title, c, profile.Name)
+									resetMigrateButton()
+									if err2 != nil {
+										walk.MsgBox(mw, "Error", fmt.Sprintf("Failed to show %s profiles: %s", patch.OpenSpy.DisplayName, err2.Error()), walk.MsgBoxIconError)
+									}
+								})
+							}()
+						},
+					},
+					declarative.Action{
+						AssignTo: &updatePasswordA,
+						Text:     "Update profile password...",
+						OnTriggered: func() {
+							profile, ok := selectedProfile()
+							if !ok || profile.Type != game.ProfileTypeMultiplayer {
+								walk.MsgBox(mw, "Warning", "Only multiplayer profiles have a password", walk.MsgBoxIconWarning)
+								return
+							}
+
+							password, ok, err2 := runPasswordDialog(mw, profile.Name)
+							if err2 != nil {
+								walk.MsgBox(mw, "Error", fmt.Sprintf("Failed to show password dialog: %s", err2.Error()), walk.MsgBoxIconError)
+								return
+							} else if !ok {
+								// User canceled dialog
+								return
+							}
+
+							if err2 = opts.Title.UpdateProfilePassword(h, profile, password); err2 != nil {
+								walk.MsgBox(mw, "Error", fmt.Sprintf("Failed to update password of %q: %s", profile.Name, err2.Error()), walk.MsgBoxIconError)
+								return
+							}
+
+							walk.MsgBox(mw, "Success", fmt.Sprintf("Updated password of %q", profile.Name), walk.MsgBoxIconInformation)
+						},
+					},
+					declarative.Action{
+						Text: "Detect provider of running game",
+						OnTriggered: func() {
+							p, ok, err2 := patch.DetectRunningProvider()
+							if err2 != nil {
+								walk.MsgBox(mw, "Error", fmt.Sprintf("Failed to detect provider of running game: %s", err2.Error()), walk.MsgBoxIconError)
+							} else if !ok {
+								walk.MsgBox(mw, "Running game", fmt.Sprintf("Running game does not use %s, the provider cannot be determined any further from the running process", patch.BF2Hub.DisplayName), walk.MsgBoxIconInformation)
+							} else {
+								walk.MsgBox(mw, "Running game", fmt.Sprintf("Running game uses %s", p.DisplayName), walk.MsgBoxIconInformation)
+							}
+						},
+					},
+					declarative.Action{
+						Text: "Find installations...",
+						OnTriggered: func() {
+							dlg := &walk.FileDialog{
+								Title: "Choose folder to search for game installations",
+							}
+
+							ok, err2 := dlg.ShowBrowseFolder(mw)
+							if err2 != nil {
+								walk.MsgBox(mw, "Error", fmt.Sprintf("Failed to choose folder: %s", err2.Error()), walk.MsgBoxIconError)
+								return
+							} else if !ok {
+								// User canceled dialog
+								return
+							}
+
+							mw.SetEnabled(false)
+							go func() {
+								found, err3 := patch.ScanInstalls(dlg.FilePath, patch.DefaultScanDepth)
+								mw.Synchronize(func() {
+									mw.SetEnabled(true)
+									if err3 != nil {
+										walk.MsgBox(mw, "Error", fmt.Sprintf("Failed to search for game installations: %s", err3.Error()), walk.MsgBoxIconError)
+										return
+									}
+
+									if len(found) == 0 {
+										walk.MsgBox(mw, "Find installations", fmt.Sprintf("No game installations found in %s", dlg.FilePath), walk.MsgBoxIconInformation)
+										return
+									}
+
+									var sb strings.Builder
+									for _, install := range found {
+										if install.Err != nil {
+											fmt.Fprintf(&sb, "%s: unknown provider (%s)\r\n", install.Dir, install.Err)
+										} else {
+											fmt.Fprintf(&sb, "%s: %s\r\n", install.Dir, install.Provider.DisplayName)
+										}
+									}
+
+									if len(found) > 1 {
+										if err4 := runTextDialog(mw, "Find installations", sb.String()); err4 != nil {
+											walk.MsgBox(mw, "Error", fmt.Sprintf("Failed to show game installations: %s", err4.Error()), walk.MsgBoxIconError)
+										}
+										return
+									}
+
+									// Only a single installation, so offer using it right away
+									if walk.MsgBox(mw, "Find installations", fmt.Sprintf("Found %s\nUse this installation?", sb.String()), walk.MsgBoxYesNo|walk.MsgBoxIconQuestion) == win.IDYES {
+										enablePatch(found[0].Dir)
+									}
+								})
+							}()
+						},
+					},
+					declarative.Action{
+						Text: "Export eligibility report...",
+						OnTriggered: func() {
+							dlg := &walk.FileDialog{
+								Title:    "Save eligibility report",
+								Filter:   "CSV files (*.csv)|*.csv",
+								FilePath: "bf2-migrator-eligibility.csv",
+							}
+
+							ok, err2 := dlg.ShowSave(mw)
+							if err2 != nil {
+								walk.MsgBox(mw, "Error", fmt.Sprintf("Failed to choose report location: %s", err2.Error()), walk.MsgBoxIconError)
+								return
+							} else if !ok {
+								// User canceled dialog
+								return
+							}
+
+							results := make([]migrate.Eligibility, 0, len(allProfiles))
+							migratable := 0
+							for _, profile := range allProfiles {
+								e := opts.Title.CheckEligibility(h, profile)
+								if e.Migratable() {
+									migratable++
+								}
+								results = append(results, e)
+							}
+
+							var sb strings.Builder
+							if err2 = migrate.WriteEligibilityCSV(&sb, results); err2 != nil {
+								walk.MsgBox(mw, "Error", fmt.Sprintf("Failed to encode eligibility report: %s", err2.Error()), walk.MsgBoxIconError)
+								return
+							}
+
+							if err2 = os.WriteFile(dlg.FilePath, []byte(sb.String()), 0o644); err2 != nil {
+								walk.MsgBox(mw, "Error", fmt.Sprintf("Failed to write eligibility report: %s", err2.Error()), walk.MsgBoxIconError)
+								return
+							}
+
+							walk.MsgBox(mw, "Success", fmt.Sprintf("%d of %d profile(s) can be migrated, saved details to %s", migratable, len(results), dlg.FilePath), walk.MsgBoxIconInformation)
+						},
+					},
+					declarative.Action{
+						Text: "Export patch script...",
+						OnTriggered: func() {
+							dir := pathTE.Text()
+							if dir == "" {
+								walk.MsgBox(mw, "Warning", "Please detect or choose the game installation folder first", walk.MsgBoxIconWarning)
+								return
+							}
+
+							p := providerCB.Model().([]patch.Provider)[providerCB.CurrentIndex()]
+							script, err2 := patch.ExportScript(dir, p)
+							if err2 != nil {
+								walk.MsgBox(mw, "Error", fmt.Sprintf("Failed to compute changes required to patch %s: %s", patch.BF2ExecutableName, err2.Error()), walk.MsgBoxIconError)
+								return
+							}
+
+							dlg := &walk.FileDialog{
+								Title:    "Save patch script",
+								Filter:   "JSON files (*.json)|*.json",
+								FilePath: fmt.Sprintf("bf2-patch-%s-to-%s.json", strings.ToLower(script.From), strings.ToLower(script.To)),
+							}
+
+							ok, err2 := dlg.ShowSave(mw)
+							if err2 != nil {
+								walk.MsgBox(mw, "Error", fmt.Sprintf("Failed to choose patch script location: %s", err2.Error()), walk.MsgBoxIconError)
+								return
+							} else if !ok {
+								// User canceled dialog
+								return
+							}
+
+							b, err2 := json.MarshalIndent(script, "", "  ")
+							if err2 != nil {
+								walk.MsgBox(mw, "Error", fmt.Sprintf("Failed to encode patch script: %s", err2.Error()), walk.MsgBoxIconError)
+								return
+							}
+
+							if err2 = os.WriteFile(dlg.FilePath, b, 0o644); err2 != nil {
+								walk.MsgBox(mw, "Error", fmt.Sprintf("Failed to write patch script: %s", err2.Error()), walk.MsgBoxIconError)
+								return
+							}
+
+							walk.MsgBox(mw, "Success", fmt.Sprintf("Saved %d change(s) to %s", len(script.Changes), dlg.FilePath), walk.MsgBoxIconInformation)
+						},
+					},
+					declarative.Action{
+						Text: "Clean up backups...",
+						OnTriggered: func() {
+							dir := pathTE.Text()
+							if dir == "" {
+								walk.MsgBox(mw, "Warning", "Please detect or choose the game installation folder first", walk.MsgBoxIconWarning)
+								return
+							}
+
+							if err2 := runBackupsDialog(mw, dir); err2 != nil {
+								walk.MsgBox(mw, "Error", fmt.Sprintf("Failed to show backups: %s", err2.Error()), walk.MsgBoxIconError)
+							}
+						},
+					},
+					declarative.Action{
+						Text: "Undo last patch",
+						OnTriggered: func() {
+							dir := pathTE.Text()
+							if dir == "" {
+								walk.MsgBox(mw, "Warning", "Please detect or choose the game installation folder first", walk.MsgBoxIconWarning)
+								return
+							}
+
+							// Fall back to state persisted before the tool was restarted
+							s := undoState
+							if s == nil || s.Dir != dir {
+								loaded, err2 := patch.LoadUndoState(dir, time.Now())
+								if errors.Is(err2, patch.ErrNoUndoState) {
+									walk.MsgBox(mw, "Undo last patch", fmt.Sprintf("There is no patch to undo (patches can be undone for %s)", patch.UndoMaxAge), walk.MsgBoxIconInformation)
+									return
+								} else if err2 != nil {
+									walk.MsgBox(mw, "Error", fmt.Sprintf("Failed to load undo state: %s", err2.Error()), walk.MsgBoxIconError)
+									return
+								}
+								s = &loaded
+							}
+
+							confirmed := walk.MsgBox(
+								mw,
+								"Undo last patch",
+								fmt.Sprintf("This will restore %s as it was before it was last patched at %s.\n\nContinue?", patch.BF2ExecutableName, s.CreatedAt.Format("15:04")),
+								walk.MsgBoxYesNo|walk.MsgBoxIconQuestion,
+							)
+							if confirmed != win.IDYES {
+								return
+							}
+
+							// Block any actions during patching
+							mw.SetEnabled(false)
+							defer mw.SetEnabled(true)
+
+							// Same as patching, the game needs to be closed and BF2Hub must not re-patch the restored binary
+							mayRepatch, err2 := prepareForPatch()
+							if errors.Is(err2, patch.ErrAborted) {
+								return
+							} else if err2 != nil {
+								walk.MsgBox(mw, "Error", fmt.Sprintf("Failed to prepare for undoing last patch: %s", err2.Error()), walk.MsgBoxIconError)
+								return
+							}
+
+							if mayRepatch {
+								walk.MsgBox(mw, "Warning", fmt.Sprintf("%s is installed and its settings were not modified, it may re-patch %s", patch.BF2Hub.DisplayName, patch.BF2ExecutableName), walk.MsgBoxIconWarning)
+							}
+
+							p, err2 := patch.Undo(*s)
+							if err2 != nil {
+								walk.MsgBox(mw, "Error", fmt.Sprintf("Failed to undo last patch: %s", err2.Error()), walk.MsgBoxIconError)
+								return
+							}
+
+							undoState = nil
+							updateStatus()
+							walk.MsgBox(mw, "Success", fmt.Sprintf("Restored %s, it now uses %s", patch.BF2ExecutableName, p.DisplayName), walk.MsgBoxIconInformation)
+						},
+					},
+					declarative.Action{
+						Text: "Restore backup...",
+						OnTriggered: func() {
+							dir := pathTE.Text()
+							if dir == "" {
+								walk.MsgBox(mw, "Warning", "Please detect or choose the game installation folder first", walk.MsgBoxIconWarning)
+								return
+							}
+
+							restored, err2 := runRestoreBackupDialog(mw, r, opts, dir)
+							if err2 != nil {
+								walk.MsgBox(mw, "Error", fmt.Sprintf("Failed to show backups: %s", err2.Error()), walk.MsgBoxIconError)
+							} else if restored {
+								updateStatus()
+							}
+						},
+					},
+					declarative.Action{
+						Text: "Import backup file...",
+						OnTriggered: func() {
+							dir := pathTE.Text()
+							if dir == "" {
+								walk.MsgBox(mw, "Warning", "Please detect or choose the game installation folder first", walk.MsgBoxIconWarning)
+								return
+							}
+
+							if importBackup(mw, r, opts, dir) {
+								updateStatus()
+							}
+						},
+					},
+					declarative.Action{
+						Text: "Revert for uninstall...",
+						OnTriggered: func() {
+							dir := pathTE.Text()
+							if dir == "" {
+								walk.MsgBox(mw, "Warning", "Please detect or choose the game installation folder first", walk.MsgBoxIconWarning)
+								return
+							}
+
+							confirmed := walk.MsgBox(
+								mw,
+								"Revert for uninstall",
+								fmt.Sprintf("This will revert %s to use %s and re-enable %s patching (if installed). Any running game or %s processes will be closed.\n\nContinue?", patch.BF2ExecutableName, patch.GameSpy.DisplayName, patch.BF2Hub.DisplayName, patch.BF2Hub.DisplayName),
+								walk.MsgBoxYesNo|walk.MsgBoxIconQuestion,
+							)
+							if confirmed != win.IDYES {
+								return
+							}
+
+							// Block any actions during patching
+							mw.SetEnabled(false)
+							defer func() {
+								mw.SetEnabled(true)
+								updateStatus()
+							}()
+
+							result, err2 := patch.RevertForUninstall(r, dir)
+							if err2 != nil {
+								walk.MsgBox(mw, "Error", fmt.Sprintf("Failed to revert installation: %s", err2.Error()), walk.MsgBoxIconError)
+								return
+							}
+							// BF2Hub patching was re-enabled as part of the revert
+							bf2HubSettings = nil
+
+							if result.NoOp {
+								walk.MsgBox(mw, "Success", "Installation is already stock, the game can now be uninstalled", walk.MsgBoxIconInformation)
+							} else {
+								walk.MsgBox(mw, "Success", "Reverted installation to stock, the game can now be uninstalled", walk.MsgBoxIconInformation)
+							}
+						},
+					},
+				},
+			},
+			declarative.Menu{
+				Text: "&Help",
+				Items: []declarative.MenuItem{
+					declarative.Action{
+						Text: "Generate support report...",
+						OnTriggered: func() {
+							dlg := &walk.FileDialog{
+								Title:    "Save support report",
+								Filter:   "Text files (*.txt)|*.txt",
+								FilePath: "bf2-migrator-report.txt",
+							}
+
+							ok, err2 := dlg.ShowSave(mw)
+							if err2 != nil {
+								walk.MsgBox(mw, "Error", fmt.Sprintf("Failed to choose report location: %s", err2.Error()), walk.MsgBoxIconError)
+								return
+							} else if !ok {
+								// User canceled dialog
+								return
+							}
+
+							report := buildSupportReport(r, pathTE.Text())
+							if err2 = os.WriteFile(dlg.FilePath, []byte(report), 0o644); err2 != nil {
+								walk.MsgBox(mw, "Error", fmt.Sprintf("Failed to write support report: %s", err2.Error()), walk.MsgBoxIconError)
+								return
+							}
+
+							walk.MsgBox(mw, "Success", fmt.Sprintf("Saved support report to %s\n\nPlease attach it to your bug report", dlg.FilePath), walk.MsgBoxIconInformation)
+						},
+					},
+				},
+			},
+		},
 		Children: []declarative.Widget{
 			declarative.GroupBox{
 				Title:  "Migrate",
@@ -136,6 +993,21 @@ func CreateMainWindow(h game.Handler, c client, f finder, r registryRepository)
 						TextColor:  walk.Color(win.GetSysColor(win.COLOR_CAPTIONTEXT)),
 						Background: declarative.SolidColorBrush{Color: walk.Color(win.GetSysColor(win.COLOR_BTNFACE))},
 					},
+					declarative.LineEdit{
+						AssignTo:  &profileFilterLE,
+						Name:      "Filter profiles",
+						CueBanner: "Type to filter profiles",
+						// Only shown if there are many profiles
+						Visible: false,
+						OnTextChanged: func() {
+							filtered := filterProfiles(allProfiles, profileFilterLE.Text())
+							_ = profileCB.SetModel(filtered)
+							if len(filtered) > 0 {
+								_ = profileCB.SetCurrentIndex(0)
+							}
+							updateActionStates(selectedProfile())
+						},
+					},
 					declarative.ComboBox{
 						AssignTo:      &profileCB,
 						DisplayMember: "Name",
@@ -143,33 +1015,97 @@ func CreateMainWindow(h game.Handler, c client, f finder, r registryRepository)
 						Name:          "Select profile",
 						ToolTipText:   "Select profile",
 						OnCurrentIndexChanged: func() {
-							// Password actions cannot be used with singleplayer profiles, since those don't have passwords
-							if profileCB.Model().([]game.Profile)[profileCB.CurrentIndex()].Type == game.ProfileTypeMultiplayer {
-								migratePB.SetEnabled(true)
-							} else {
-								migratePB.SetEnabled(false)
-							}
+							updateActionStates(selectedProfile())
 						},
 					},
 					declarative.PushButton{
 						AssignTo: &migratePB,
-						Text:     "Migrate to OpenSpy",
+						Text:     migrateButtonText,
 						OnClicked: func() {
+							profile, ok := selectedProfile()
+							if !ok {
+								return
+							}
+
+							// On shared PCs, make sure users don't accidentally migrate someone else's profile
+							if !cfg.SkipProfileOwnerCheck {
+								owner, isCurrentUser, err2 := opts.Title.CheckProfileOwner(h, profile)
+								if err2 != nil {
+									// Only a safeguard, so don't block migrating if the owner cannot be determined
+									log.Warn().
+										Err(err2).
+										Str("profile", profile.Name).
+										Msg("Failed to determine owner of profile folder")
+								} else if !isCurrentUser {
+									confirmed := walk.MsgBox(
+										mw,
+										"Warning",
+										fmt.Sprintf("The folder of profile %q belongs to another Windows user (%s). Make sure you are migrating your own profile.\n\nMigrate anyway?", profile.Name, owner),
+										walk.MsgBoxYesNo|walk.MsgBoxIconWarning,
+									)
+									if confirmed != win.IDYES {
+										return
+									}
+								}
+							}
+
 							// Block any actions during migrations
 							mw.SetEnabled(false)
 							_ = migratePB.SetText("Migrating...")
-							defer func() {
-								_ = migratePB.SetText("Migrate to OpenSpy")
+
+							done := func(created bool, err2 error) {
+								resetMigrateButton()
 								mw.SetEnabled(true)
-							}()
 
-							profile := profileCB.Model().([]game.Profile)[profileCB.CurrentIndex()]
-							err2 := migrateProfile(h, c, profile.Key)
-							if err2 != nil {
-								walk.MsgBox(mw, "Error", fmt.Sprintf("Failed to migrate %q to OpenSpy: %s", profile.Name, err2.Error()), walk.MsgBoxIconError)
-							} else {
-								walk.MsgBox(mw, "Success", fmt.Sprintf("Migrated %q to OpenSpy", profile.Name), walk.MsgBoxIconInformation)
+								if err2 != nil {
+									log.Error().Err(err2).Str("profile", profile.Name).Msg("Failed to migrate profile")
+									walk.MsgBox(mw, "Error", fmt.Sprintf("Failed to migrate %q to %s: %s", profile.Name, patch.OpenSpy.DisplayName, err2.Error()), walk.MsgBoxIconError)
+									return
+								}
+
+								log.Info().Str("profile", profile.Name).Bool("created", created).Msg("Migrated profile")
+								if !created {
+									walk.MsgBox(mw, "Success", fmt.Sprintf("Migrated %q to %s (the profile already existed)", profile.Name, patch.OpenSpy.DisplayName), walk.MsgBoxIconInformation)
+								} else {
+									walk.MsgBox(mw, "Success", fmt.Sprintf("Migrated %q to %s", profile.Name, patch.OpenSpy.DisplayName), walk.MsgBoxIconInformation)
+								}
 							}
+
+							// Migrate in the background to keep the window responsive (and able to show retry progress)
+							go func() {
+								nick, existing, err2 := opts.Title.FindExistingProfiles(h, c, profile)
+								mw.Synchronize(func() {
+									if err2 != nil {
+										done(false, err2)
+										return
+									}
+
+									// Let users know up front whether anything will be created, avoiding confusion about "nothing happening"
+									icon := walk.MsgBoxIconQuestion
+									if len(opts.Title.OtherNicks(existing, nick)) > 0 && !opts.Title.HasProfile(existing, nick) {
+										// Account may belong to someone else, make sure the user doesn't confirm out of habit
+										icon = walk.MsgBoxIconWarning
+									}
+									confirmed := walk.MsgBox(
+										mw,
+										fmt.Sprintf("Migrate to %s", patch.OpenSpy.DisplayName),
+										fmt.Sprintf("%s\n\nContinue?", opts.Title.DescribeExistingProfiles(nick, existing)),
+										walk.MsgBoxYesNo|icon,
+									)
+									if confirmed != win.IDYES {
+										resetMigrateButton()
+										mw.SetEnabled(true)
+										return
+									}
+
+									go func() {
+										created, err3 := opts.Title.CreateProfileUnlessExists(c, nick, existing)
+										mw.Synchronize(func() {
+											done(created, err3)
+										})
+									}()
+								})
+							}()
 						},
 					},
 				},
@@ -189,19 +1125,15 @@ func CreateMainWindow(h game.Handler, c client, f finder, r registryRepository)
 						Name:     "Installation folder",
 						ReadOnly: true,
 					},
+					declarative.Label{
+						AssignTo: &statusLbl,
+						Text:     "Current provider: unknown",
+					},
 					declarative.HSplitter{
 						Children: []declarative.Widget{
 							declarative.PushButton{
-								Text: "Detect",
-								OnClicked: func() {
-									detected, err2 := detectInstallPath(f)
-									if err2 != nil {
-										walk.MsgBox(mw, "Warning", "Could not detect game installation folder, please choose the path manually", walk.MsgBoxIconWarning)
-										return
-									}
-
-									enablePatch(detected)
-								},
+								Text:      "Detect",
+								OnClicked: detectInstallPath,
 							},
 							declarative.PushButton{
 								Text: "Choose",
@@ -237,14 +1169,14 @@ func CreateMainWindow(h game.Handler, c client, f finder, r registryRepository)
 							},
 							declarative.ComboBox{
 								AssignTo:      &providerCB,
-								DisplayMember: "Name",
+								DisplayMember: "DisplayName",
 								BindingMember: "Name",
 								Name:          "Select provider",
 								ToolTipText:   "Select provider",
-								Model: []provider{
+								Model: []patch.Provider{
 									// Not offering BF2Hub (needs a .dll in addition to .exe changes)
-									playbf2,
-									openspy,
+									patch.PlayBF2,
+									patch.OpenSpy,
 									// Not offering GameSpy (obsolete, only used for reverting)
 								},
 								CurrentIndex: 1, // Select OpenSpy as default
@@ -252,59 +1184,16 @@ func CreateMainWindow(h game.Handler, c client, f finder, r registryRepository)
 							declarative.HSplitter{
 								Children: []declarative.Widget{
 									declarative.PushButton{
-										AssignTo: &patchPB,
-										Text:     "Apply patch",
-										Enabled:  false,
-										OnClicked: func() {
-											// Block any actions during patching
-											mw.SetEnabled(false)
-											_ = patchPB.SetText("Patching...")
-											defer func() {
-												_ = patchPB.SetText("Apply patch")
-												mw.SetEnabled(true)
-											}()
-
-											err2 := prepareForPatch(r)
-											if err2 != nil {
-												walk.MsgBox(mw, "Error", fmt.Sprintf("Failed to prepare for patching %s: %s", bf2ExecutableName, err2.Error()), walk.MsgBoxIconError)
-												return
-											}
-
-											p := providerCB.Model().([]provider)[providerCB.CurrentIndex()]
-											err2 = patchBinary(pathTE.Text(), p)
-											if err2 != nil {
-												walk.MsgBox(mw, "Error", fmt.Sprintf("Failed to patch %s: %s", bf2ExecutableName, err2.Error()), walk.MsgBoxIconError)
-											} else {
-												walk.MsgBox(mw, "Success", fmt.Sprintf("Patched %s to use %s", bf2ExecutableName, p.Name), walk.MsgBoxIconInformation)
-											}
-										},
+										AssignTo:  &patchPB,
+										Text:      "Apply patch",
+										Enabled:   false,
+										OnClicked: applyPatch,
 									},
 									declarative.PushButton{
-										AssignTo: &revertPB,
-										Text:     "Revert patch",
-										Enabled:  false,
-										OnClicked: func() {
-											// Block any actions during patching
-											mw.SetEnabled(false)
-											_ = revertPB.SetText("Reverting...")
-											defer func() {
-												_ = revertPB.SetText("Revert patch")
-												mw.SetEnabled(true)
-											}()
-
-											err2 := prepareForPatch(r)
-											if err2 != nil {
-												walk.MsgBox(mw, "Error", fmt.Sprintf("Failed to prepare for reverting %s: %s", bf2ExecutableName, err2.Error()), walk.MsgBoxIconError)
-												return
-											}
-
-											err2 = patchBinary(pathTE.Text(), gamespy)
-											if err2 != nil {
-												walk.MsgBox(mw, "Error", fmt.Sprintf("Failed to patch %s: %s", bf2ExecutableName, err2.Error()), walk.MsgBoxIconError)
-											} else {
-												walk.MsgBox(mw, "Success", fmt.Sprintf("Reverted %s to use GameSpy\n\nYou can now use provider-specific patchers again (e.g. BF2Hub Patcher)", bf2ExecutableName), walk.MsgBoxIconInformation)
-											}
-										},
+										AssignTo:  &revertPB,
+										Text:      "Revert patch",
+										Enabled:   false,
+										OnClicked: revertPatch,
 									},
 								},
 							},
@@ -313,355 +1202,123 @@ func CreateMainWindow(h game.Handler, c client, f finder, r registryRepository)
 				},
 			},
 			declarative.Label{
-				Text:       "BF2 migrator v0.5.0",
+				Text:       fmt.Sprintf("BF2 migrator %s", version),
 				Alignment:  declarative.AlignHCenterVCenter,
 				TextColor:  walk.Color(win.GetSysColor(win.COLOR_GRAYTEXT)),
 				Background: declarative.SolidColorBrush{Color: walk.Color(win.GetSysColor(win.COLOR_BTNFACE))},
 			},
 		},
 	}).Create(); err != nil {
-		return nil, err
-	}
-
-	// Disable minimize/maximize buttons and fix size
-	win.SetWindowLong(mw.Handle(), win.GWL_STYLE, win.GetWindowLong(mw.Handle(), win.GWL_STYLE) & ^win.WS_MINIMIZEBOX & ^win.WS_MAXIMIZEBOX & ^win.WS_SIZEBOX)
-
-	profiles, selected, err := getProfiles(h)
-	if err != nil {
-		walk.MsgBox(mw, "Error", fmt.Sprintf("Failed to load list of available profiles: %s", err.Error()), walk.MsgBoxIconError)
-		return nil, err
-	}
-	_ = profileCB.SetModel(profiles)
-	_ = profileCB.SetCurrentIndex(selected)
-
-	// Automatically try to detect install path once, pre-filling path if path is detected
-	detected, err := detectInstallPath(f)
-	if err == nil {
-		enablePatch(detected)
-	}
-
-	return mw, nil
-}
-
-func getProfiles(h game.Handler) ([]game.Profile, int, error) {
-	profiles, err := bf2.GetProfiles(h)
-	if err != nil {
-		return nil, 0, err
-	}
-
-	defaultProfileKey, err := bf2.GetDefaultProfileKey(h)
-	if err != nil {
 		log.Error().
 			Err(err).
-			Msg("Failed to get default profile key")
-		// If determining the default profile fails, simply pre-select the first profile (don't return an error)
-		return profiles, 0, nil
-	}
-
-	for i, profile := range profiles {
-		if profile.Key == defaultProfileKey {
-			return profiles, i, nil
+			Msg("Failed to create main window, falling back to minimal window")
+		if mw != nil {
+			mw.Dispose()
 		}
+		cancel()
+		return createFallbackWindow(opts, f, r)
 	}
 
-	return profiles, 0, nil
-}
-
-func migrateProfile(h game.Handler, c client, profileKey string) error {
-	profileCon, err := bf2.ReadProfileConfigFile(h, profileKey, bf2.ProfileConfigFileProfileCon)
-	if err != nil {
-		return fmt.Errorf("failed to read profile config file: %w", err)
-	}
-
-	nick, encrypted, err := bf2.GetEncryptedLogin(profileCon)
-	if err != nil {
-		return fmt.Errorf("failed to get encrypted login from profile config file: %w", err)
-	}
-
-	password, err := bf2.DecryptProfileConPassword(encrypted)
-	if err != nil {
-		return fmt.Errorf("failed to decrypt profile password: %w", err)
-	}
-
-	email, err := profileCon.GetValue(bf2.ProfileConKeyEmail)
-	if err != nil {
-		return fmt.Errorf("failed to get email address from profile config file: %w", err)
-	}
-
-	err = c.CreateAccount(email.String(), password, 0)
-	if err != nil {
-		return fmt.Errorf("failed to create OpenSpy account: %w", err)
-	}
+	// Disable minimize/maximize buttons and fix size
+	win.SetWindowLong(mw.Handle(), win.GWL_STYLE, win.GetWindowLong(mw.Handle(), win.GWL_STYLE) & ^win.WS_MINIMIZEBOX & ^win.WS_MAXIMIZEBOX & ^win.WS_SIZEBOX)
 
-	profiles, err := c.GetProfiles()
-	if err != nil {
-		return fmt.Errorf("failed to get OpenSpy account profiles: %w", err)
+	if cfg.AlwaysOnTop {
+		setAlwaysOnTop(mw.Handle(), true)
 	}
 
-	// Don't use slices package here to maintain compatibility with go 1.20 (and thus Windows 7)
-	exists := false
-	for _, profile := range profiles {
-		if profile.UniqueNick == nick && profile.NamespaceID == 12 {
-			exists = true
-			break
+	// Hide the window instead of minimizing it to the taskbar while the tray icon is present
+	mw.SizeChanged().Attach(func() {
+		if trayIcon != nil && win.IsIconic(mw.Handle()) {
+			mw.Hide()
 		}
-	}
+	})
 
-	if !exists {
-		err2 := c.CreateProfile(nick, 12)
-		if err2 != nil {
-			return fmt.Errorf("failed to create OpenSpy profile: %w", err2)
+	if cfg.MinimizeToTray {
+		if err = setTrayEnabled(true); err != nil {
+			log.Error().
+				Err(err).
+				Msg("Failed to add system tray icon")
 		}
 	}
 
-	return nil
-}
-
-func prepareForPatch(r registryRepository) error {
-	processes, err := ps.Processes()
-	if err != nil {
-		return fmt.Errorf("failed to retrieve process list: %s", err)
-	}
-
-	killed := map[int]string{}
-	for _, process := range processes {
-		executable := process.Executable()
-		if executable == bf2ExecutableName || executable == bf2hubExecutableName {
-			pid := process.Pid()
-			if err = killProcess(pid); err != nil {
-				return fmt.Errorf("failed to kill process %q: %s", executable, err)
+	mw.Closing().Attach(func(canceled *bool, reason walk.CloseReason) {
+		// Don't leave BF2Hub disabled if the session ended without the game being patched
+		if bf2HubSettings != nil {
+			answer := walk.MsgBox(
+				mw,
+				"Restore BF2Hub settings",
+				fmt.Sprintf("%s was stopped from re-patching %s during this session. Restore its previous settings before quitting?\n\nChoose \"No\" to keep %s from re-patching the game.", patch.BF2Hub.DisplayName, patch.BF2ExecutableName, patch.BF2Hub.DisplayName),
+				walk.MsgBoxYesNoCancel|walk.MsgBoxIconQuestion,
+			)
+			switch answer {
+			case win.IDCANCEL:
+				*canceled = true
+				return
+			case win.IDYES:
+				if err2 := patch.RestoreBF2HubSettings(r, *bf2HubSettings); err2 != nil {
+					walk.MsgBox(mw, "Error", fmt.Sprintf("Failed to restore %s settings: %s", patch.BF2Hub.DisplayName, err2.Error()), walk.MsgBoxIconError)
+				} else {
+					log.Info().Msg("Restored BF2Hub settings")
+				}
 			}
-			killed[pid] = executable
+			bf2HubSettings = nil
 		}
-	}
 
-	err = waitForProcessesToExit(killed)
-	if err != nil {
-		return err
-	}
-
-	// Stop BF2Hub from re-patching the binary
-	err = r.OpenKey(registry.CURRENT_USER, "SOFTWARE\\BF2Hub Systems\\BF2Hub Client", registry.QUERY_VALUE|registry.SET_VALUE, func(key registry.Key) error {
-		if err2 := key.SetDWordValue("hrpApplyOnStartup", 0); err2 != nil {
-			return err2
-		}
+		// Abort any requests still in flight rather than leaving them to linger once the window is gone
+		cancel()
 
-		if err2 := key.SetDWordValue("hrpInterval", 0); err2 != nil {
-			return err2
+		// Tray icons are not removed automatically and would linger until hovered over
+		if trayIcon != nil {
+			_ = trayIcon.Dispose()
+			trayIcon = nil
 		}
-
-		return nil
 	})
-	if err != nil {
-		// Ignore error if key does not exist, as it would indicate that the BF2Hub Client is not installed and thus
-		// cannot interfere with patching
-		if !errors.Is(err, registry.ErrNotExist) {
-			return err
-		}
-	}
-
-	return nil
-}
 
-func detectInstallPath(f finder) (string, error) {
-	// Copied from https://github.com/cetteup/joinme.click-launcher/blob/089fb595adc426aab775fe40165431501a5c38c3/internal/titles/bf2.go#L37
-	dir, err := f.GetInstallDirFromSomewhere([]software_finder.Config{
-		{
-			ForType:           software_finder.RegistryFinder,
-			RegistryKey:       software_finder.RegistryKeyLocalMachine,
-			RegistryPath:      "SOFTWARE\\WOW6432Node\\Electronic Arts\\EA Games\\Battlefield 2",
-			RegistryValueName: "InstallDir",
-		},
-		{
-			ForType:           software_finder.RegistryFinder,
-			RegistryKey:       software_finder.RegistryKeyCurrentUser,
-			RegistryPath:      "SOFTWARE\\BF2Hub Systems\\BF2Hub Client",
-			RegistryValueName: "bf2Dir",
-		},
-	})
-	if err != nil {
-		return "", fmt.Errorf("failed to determine Battlefield 2 install directory: %w", err)
-	}
-
-	return dir, err
-}
-
-func patchBinary(dir string, new provider) error {
-	path := filepath.Join(dir, bf2ExecutableName)
-
-	stats, err := os.Stat(path)
+	profiles, selected, err := opts.Title.GetProfiles(h)
 	if err != nil {
-		return err
-	}
-
-	original, err := os.ReadFile(path)
-	if err != nil {
-		return err
-	}
-
-	// Detect "old"/current provider based on what's in the binary
-	old, err := determineCurrentlyUsedProvider(original)
-	if err != nil {
-		return err
+		walk.MsgBox(mw, "Error", fmt.Sprintf("Failed to load list of available profiles: %s", err.Error()), walk.MsgBoxIconError)
+		cancel()
+		return nil, err
 	}
+	allProfiles = profiles
+	_ = profileCB.SetModel(profiles)
+	_ = profileCB.SetCurrentIndex(selected)
+	updateActionStates(selectedProfile())
 
-	// No need to patch if binary is already patched as desired
-	if new.Name == old.Name {
-		return nil
+	if len(profiles) > profileFilterThreshold {
+		profileFilterLE.SetVisible(true)
+		_ = mw.SetHeight(mw.Height() + profileFilterHeight)
 	}
 
-	modifications := getModifications(old, new)
-	modified := original[:]
-	for _, m := range modifications {
-		o := padRight(m.Old, 0, m.Length)
-		n := padRight(m.New, 0, m.Length)
-
-		count := bytes.Count(modified, o)
-		if count != m.Count {
-			return fmt.Errorf("binary contains unknown modifications, revert changes first")
-		}
-
-		// Replace all occurrences, making sure to keep the binary the same length
-		modified = bytes.ReplaceAll(modified, o, n)
-	}
+	c.OnRetry(func(attempt, maxAttempts int, wait time.Duration) {
+		mw.Synchronize(func() {
+			_ = migratePB.SetText(fmt.Sprintf("Attempt %d of %d, waiting %s...", attempt, maxAttempts, wait))
+		})
+	})
 
-	// Any changes to the length would break the binary
-	if len(modified) != len(original) {
-		return fmt.Errorf("length of modified binary does not match length of original")
+	// Automatically try to detect install path once, pre-filling path if path is detected
+	detected, err := patch.DetectInstallPath(f)
+	if err == nil {
+		enablePatch(detected)
 	}
 
-	return os.WriteFile(path, modified, stats.Mode())
+	return mw, nil
 }
 
-func determineCurrentlyUsedProvider(b []byte) (provider, error) {
-	for _, p := range []provider{bf2hub, playbf2, openspy, gamespy} {
-		ridges := append(p.Fingerprint.Additional, p.Fingerprint.Hostname, p.Fingerprint.HostsPath)
-		if containsAll(b, ridges) {
-			return p, nil
+// networkTargets returns a connectivity check target for each distinct provider hostname
+func networkTargets() []diagnostics.Target {
+	targets := make([]diagnostics.Target, 0, len(patch.Providers))
+	seen := map[string]int{}
+	for _, p := range patch.Providers {
+		hostname := string(p.Fingerprint.Hostname)
+		// Some providers share a hostname (e.g. BF2Hub and GameSpy), so combine them into a single target
+		if i, ok := seen[hostname]; ok {
+			targets[i].Name += "/" + p.DisplayName
+			continue
 		}
+		seen[hostname] = len(targets)
+		targets = append(targets, diagnostics.Target{Name: p.DisplayName, Hostname: hostname})
 	}
 
-	return provider{}, fmt.Errorf("binary contains unknown/mixed modifications, revert changes first")
-}
-
-type modification struct {
-	Old    []byte
-	New    []byte
-	Length int
-	Count  int
-}
-
-func getModifications(old, new provider) []modification {
-	// Default modifications, required for patching any provider
-	modifications := []modification{
-		{
-			Old:    old.Fingerprint.HostsPath,
-			New:    new.Fingerprint.HostsPath,
-			Length: 18,
-			Count:  1,
-		},
-		{
-			Old:    []byte(fmt.Sprintf("gamestats.%s", old.Fingerprint.Hostname)),
-			New:    []byte(fmt.Sprintf("gamestats.%s", new.Fingerprint.Hostname)),
-			Length: 21,
-			Count:  2,
-		},
-		{
-			Old:    []byte(fmt.Sprintf("http://stage-net.%s/bf2/getplayerinfo.aspx?pid=", old.Fingerprint.Hostname)),
-			New:    []byte(fmt.Sprintf("http://stage-net.%s/bf2/getplayerinfo.aspx?pid=", new.Fingerprint.Hostname)),
-			Length: 56,
-			Count:  1,
-		},
-		{
-			Old: []byte(fmt.Sprintf("BF2Web.%s", old.Fingerprint.Hostname)),
-			New: []byte(fmt.Sprintf("BF2Web.%s", new.Fingerprint.Hostname)),
-			// Actual length of original is 18. However, "BF2Web.%s" would also match the below modification
-			// and break the url, so add another trailing nil-byte to avoid the partial match
-			Length: 19,
-			Count:  1,
-		},
-		{
-			Old:    []byte(fmt.Sprintf("http://BF2Web.%s/ASP/", old.Fingerprint.Hostname)),
-			New:    []byte(fmt.Sprintf("http://BF2Web.%s/ASP/", new.Fingerprint.Hostname)),
-			Length: 30,
-			Count:  1,
-		},
-		{
-			Old:    []byte(fmt.Sprintf("%%s.available.%s", old.Fingerprint.Hostname)),
-			New:    []byte(fmt.Sprintf("%%s.available.%s", new.Fingerprint.Hostname)),
-			Length: 24,
-			Count:  1,
-		},
-		{
-			Old:    []byte(fmt.Sprintf("%%s.master.%s", old.Fingerprint.Hostname)),
-			New:    []byte(fmt.Sprintf("%%s.master.%s", new.Fingerprint.Hostname)),
-			Length: 21,
-			Count:  1,
-		},
-		{
-			Old:    []byte(fmt.Sprintf("gpcm.%s", old.Fingerprint.Hostname)),
-			New:    []byte(fmt.Sprintf("gpcm.%s", new.Fingerprint.Hostname)),
-			Length: 16,
-			Count:  1,
-		},
-		{
-			Old:    []byte(fmt.Sprintf("gpsp.%s", old.Fingerprint.Hostname)),
-			New:    []byte(fmt.Sprintf("gpsp.%s", new.Fingerprint.Hostname)),
-			Length: 16,
-			Count:  1,
-		},
-	}
-
-	// Semi backend-specific modifications (common for some backends)
-	// Special case for PlayBF2: They remove the numeric placeholder/verb ("%d") in addition to changing the hostname
-	if old.Name == playbf2.Name {
-		// Remove "%d" when currently patched for PlayBF2
-		modifications = append(modifications, modification{
-			Old:    []byte(fmt.Sprintf("%%s.ms.%s", old.Fingerprint.Hostname)),
-			New:    []byte(fmt.Sprintf("%%s.ms%%d.%s", new.Fingerprint.Hostname)),
-			Length: 19,
-			Count:  1,
-		})
-	} else if new.Name == playbf2.Name {
-		// Add "%d" when patching to PlayBF2
-		modifications = append(modifications, modification{
-			Old:    []byte(fmt.Sprintf("%%s.ms%%d.%s", old.Fingerprint.Hostname)),
-			New:    []byte(fmt.Sprintf("%%s.ms.%s", new.Fingerprint.Hostname)),
-			Length: 19,
-			Count:  1,
-		})
-	} else {
-		// Symmetrical change for all other providers
-		modifications = append(modifications, modification{
-			Old:    []byte(fmt.Sprintf("%%s.ms%%d.%s", old.Fingerprint.Hostname)),
-			New:    []byte(fmt.Sprintf("%%s.ms%%d.%s", new.Fingerprint.Hostname)),
-			Length: 19,
-			Count:  1,
-		})
-	}
-
-	// Truly backend-specific modifications (unique to a single backend to be applied/reverted)
-	switch old.Name {
-	case bf2hub.Name:
-		modifications = append(modifications, modification{
-			Old:    []byte("bf2hbc.dll"),
-			New:    []byte("WS2_32.dll"),
-			Length: 10,
-			Count:  1,
-		},
-		)
-	}
-
-	switch new.Name {
-	case bf2hub.Name:
-		modifications = append(modifications, modification{
-			Old:    []byte("WS2_32.dll"),
-			New:    []byte("bf2hbc.dll"),
-			Length: 10,
-			Count:  1,
-		},
-		)
-	}
-
-	return modifications
+	return targets
 }
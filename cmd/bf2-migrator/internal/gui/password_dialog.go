@@ -0,0 +1,75 @@
+package gui
+
+import (
+	"github.com/lxn/walk"
+	"github.com/lxn/walk/declarative"
+)
+
+// runPasswordDialog asks the user to enter a new password twice, returning false if the dialog was canceled
+func runPasswordDialog(owner walk.Form, profileName string) (string, bool, error) {
+	var dlg *walk.Dialog
+	var passwordLE *walk.LineEdit
+	var confirmLE *walk.LineEdit
+	var acceptPB *walk.PushButton
+	var cancelPB *walk.PushButton
+
+	result, err := declarative.Dialog{
+		AssignTo:      &dlg,
+		Title:         "Update password of " + profileName,
+		DefaultButton: &acceptPB,
+		CancelButton:  &cancelPB,
+		MinSize:       declarative.Size{Width: 280},
+		Layout:        declarative.VBox{},
+		Children: []declarative.Widget{
+			declarative.Label{
+				Text: "New password",
+			},
+			declarative.LineEdit{
+				AssignTo:     &passwordLE,
+				PasswordMode: true,
+			},
+			declarative.Label{
+				Text: "Confirm new password",
+			},
+			declarative.LineEdit{
+				AssignTo:     &confirmLE,
+				PasswordMode: true,
+			},
+			declarative.Composite{
+				Layout: declarative.HBox{
+					MarginsZero: true,
+				},
+				Children: []declarative.Widget{
+					declarative.HSpacer{},
+					declarative.PushButton{
+						AssignTo: &acceptPB,
+						Text:     "OK",
+						OnClicked: func() {
+							if passwordLE.Text() == "" {
+								walk.MsgBox(dlg, "Warning", "Password must not be empty", walk.MsgBoxIconWarning)
+								return
+							}
+							if passwordLE.Text() != confirmLE.Text() {
+								walk.MsgBox(dlg, "Warning", "Passwords do not match", walk.MsgBoxIconWarning)
+								return
+							}
+							dlg.Accept()
+						},
+					},
+					declarative.PushButton{
+						AssignTo: &cancelPB,
+						Text:     "Cancel",
+						OnClicked: func() {
+							dlg.Cancel()
+						},
+					},
+				},
+			},
+		},
+	}.Run(owner)
+	if err != nil {
+		return "", false, err
+	}
+
+	return passwordLE.Text(), result == walk.DlgCmdOK, nil
+}
@@ -0,0 +1,119 @@
+package gui
+
+import (
+	"fmt"
+
+	"github.com/lxn/walk"
+	"github.com/lxn/walk/declarative"
+
+	"github.com/cetteup/bf2-migrator/cmd/bf2-migrator/internal/patch"
+)
+
+// dlgCmdRefresh is returned by the process review dialog if the list of processes should be refreshed
+const dlgCmdRefresh = 100
+
+// reviewProcesses lets the user choose which of the given processes to kill before patching, returning the chosen
+// processes and whether to continue at all
+func reviewProcesses(owner walk.Form, processes []patch.BlockingProcess) ([]patch.BlockingProcess, bool) {
+	for {
+		if len(processes) == 0 {
+			return processes, true
+		}
+
+		selected, result, err := runProcessReviewDialog(owner, processes)
+		if err != nil {
+			walk.MsgBox(owner, "Error", fmt.Sprintf("Failed to show processes: %s", err.Error()), walk.MsgBoxIconError)
+			return nil, false
+		}
+
+		switch result {
+		case walk.DlgCmdOK:
+			return selected, true
+		case dlgCmdRefresh:
+			// Processes may have exited or been started in the meantime
+			refreshed, err2 := patch.FindBlockingProcesses()
+			if err2 != nil {
+				walk.MsgBox(owner, "Error", fmt.Sprintf("Failed to refresh processes: %s", err2.Error()), walk.MsgBoxIconError)
+				return nil, false
+			}
+			processes = refreshed
+		default:
+			return nil, false
+		}
+	}
+}
+
+func runProcessReviewDialog(owner walk.Form, processes []patch.BlockingProcess) ([]patch.BlockingProcess, int, error) {
+	var dlg *walk.Dialog
+	var okPB *walk.PushButton
+	var cancelPB *walk.PushButton
+
+	var selected []patch.BlockingProcess
+	boxes := make([]*walk.CheckBox, len(processes))
+	items := make([]declarative.Widget, 0, len(processes))
+	for i, p := range processes {
+		items = append(items, declarative.CheckBox{
+			AssignTo: &boxes[i],
+			Text:     p.String(),
+			Checked:  true,
+		})
+	}
+
+	result, err := declarative.Dialog{
+		AssignTo:      &dlg,
+		Title:         "Close processes",
+		DefaultButton: &okPB,
+		CancelButton:  &cancelPB,
+		MinSize:       declarative.Size{Width: 500},
+		Layout:        declarative.VBox{},
+		Children: []declarative.Widget{
+			declarative.Label{
+				Text: fmt.Sprintf("The following processes need to be closed before patching %s. Deselect any process which is not related to the game.", patch.BF2ExecutableName),
+			},
+			declarative.Composite{
+				Layout:   declarative.VBox{MarginsZero: true},
+				Children: items,
+			},
+			declarative.Composite{
+				Layout: declarative.HBox{
+					MarginsZero: true,
+				},
+				Children: []declarative.Widget{
+					declarative.PushButton{
+						Text: "Refresh",
+						OnClicked: func() {
+							dlg.Close(dlgCmdRefresh)
+						},
+					},
+					declarative.HSpacer{},
+					declarative.PushButton{
+						AssignTo: &okPB,
+						Text:     "Close selected and continue",
+						OnClicked: func() {
+							// Read selection while the check boxes still exist
+							selected = make([]patch.BlockingProcess, 0, len(processes))
+							for i, p := range processes {
+								if boxes[i].Checked() {
+									selected = append(selected, p)
+								}
+							}
+							dlg.Accept()
+						},
+					},
+					declarative.PushButton{
+						AssignTo: &cancelPB,
+						Text:     "Cancel",
+						OnClicked: func() {
+							dlg.Cancel()
+						},
+					},
+				},
+			},
+		},
+	}.Run(owner)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return selected, result, nil
+}
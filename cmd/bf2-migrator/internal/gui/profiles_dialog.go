@@ -0,0 +1,105 @@
+package gui
+
+import (
+	"fmt"
+
+	"github.com/lxn/walk"
+	"github.com/lxn/walk/declarative"
+
+	"github.com/cetteup/bf2-migrator/cmd/bf2-migrator/internal/migrate"
+)
+
+// runProfilesDialog lists the OpenSpy profiles of the (already authenticated) account and allows adding new ones
+func runProfilesDialog(owner walk.Form, t migrate.Title, c client, accountName string) error {
+	var dlg *walk.Dialog
+	var profilesLB *walk.ListBox
+	var nickLE *walk.LineEdit
+	var addPB *walk.PushButton
+	var closePB *walk.PushButton
+
+	refresh := func() error {
+		profiles, err := c.GetProfiles()
+		if err != nil {
+			return err
+		}
+
+		items := make([]string, 0, len(profiles))
+		for _, profile := range profiles {
+			if profile.NamespaceID == t.NamespaceID {
+				items = append(items, profile.UniqueNick)
+			} else {
+				items = append(items, fmt.Sprintf("%s (namespace %d)", profile.UniqueNick, profile.NamespaceID))
+			}
+		}
+
+		return profilesLB.SetModel(items)
+	}
+
+	if err := (declarative.Dialog{
+		AssignTo:      &dlg,
+		Title:         "OpenSpy profiles of " + accountName,
+		DefaultButton: &addPB,
+		CancelButton:  &closePB,
+		MinSize:       declarative.Size{Width: 300, Height: 300},
+		Layout:        declarative.VBox{},
+		Children: []declarative.Widget{
+			declarative.Label{
+				Text: "Existing profiles",
+			},
+			declarative.ListBox{
+				AssignTo: &profilesLB,
+			},
+			declarative.Label{
+				Text: "New profile nick",
+			},
+			declarative.LineEdit{
+				AssignTo: &nickLE,
+			},
+			declarative.Composite{
+				Layout: declarative.HBox{
+					MarginsZero: true,
+				},
+				Children: []declarative.Widget{
+					declarative.HSpacer{},
+					declarative.PushButton{
+						AssignTo: &addPB,
+						Text:     "Add profile",
+						OnClicked: func() {
+							nick := nickLE.Text()
+							if nick == "" {
+								walk.MsgBox(dlg, "Warning", "Please enter a nick for the new profile", walk.MsgBoxIconWarning)
+								return
+							}
+
+							if err := c.CreateProfile(nick, t.NamespaceID); err != nil {
+								walk.MsgBox(dlg, "Error", fmt.Sprintf("Failed to create OpenSpy profile %q: %s", nick, err.Error()), walk.MsgBoxIconError)
+								return
+							}
+
+							_ = nickLE.SetText("")
+							if err := refresh(); err != nil {
+								walk.MsgBox(dlg, "Error", fmt.Sprintf("Failed to get OpenSpy account profiles: %s", err.Error()), walk.MsgBoxIconError)
+							}
+						},
+					},
+					declarative.PushButton{
+						AssignTo: &closePB,
+						Text:     "Close",
+						OnClicked: func() {
+							dlg.Cancel()
+						},
+					},
+				},
+			},
+		},
+	}).Create(owner); err != nil {
+		return err
+	}
+
+	if err := refresh(); err != nil {
+		return fmt.Errorf("failed to get OpenSpy account profiles: %w", err)
+	}
+
+	dlg.Run()
+	return nil
+}
@@ -0,0 +1,106 @@
+package gui
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"golang.org/x/sys/windows/registry"
+
+	"github.com/cetteup/bf2-migrator/cmd/bf2-migrator/internal/patch"
+)
+
+// buildSupportReport collects everything needed to diagnose patching issues into a single, human-readable text
+func buildSupportReport(r registryRepository, dir string) string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "BF2 migrator %s support report\n", version)
+	fmt.Fprintf(&sb, "Generated: %s\n\n", time.Now().Format(time.RFC3339))
+
+	if dir == "" {
+		sb.WriteString("Installation folder: not set\n")
+	} else {
+		fmt.Fprintf(&sb, "Installation folder: %s\n", dir)
+		writeBinaryInfo(&sb, filepath.Join(dir, patch.BF2ExecutableName))
+	}
+
+	sb.WriteString("\n")
+	writeRunningProvider(&sb)
+
+	sb.WriteString("\n")
+	writeBF2HubRegistryState(&sb, r)
+
+	return sb.String()
+}
+
+func writeBinaryInfo(sb *strings.Builder, path string) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		fmt.Fprintf(sb, "Binary: failed to read %s: %s\n", path, err)
+		return
+	}
+
+	hash := sha256.Sum256(b)
+	fmt.Fprintf(sb, "Binary: %s (%d bytes)\n", path, len(b))
+	fmt.Fprintf(sb, "SHA-256: %s\n", hex.EncodeToString(hash[:]))
+
+	v, err := patch.ReadVersion(b)
+	if err != nil {
+		fmt.Fprintf(sb, "Detected BF2 version: unknown (%s)\n", err)
+	} else {
+		fmt.Fprintf(sb, "Detected BF2 version: %s (%s)\n", v, v.Full())
+	}
+
+	p, err := patch.DetermineCurrentlyUsedProvider(b)
+	if err != nil {
+		fmt.Fprintf(sb, "Detected provider: unknown (%s)\n", err)
+	} else {
+		fmt.Fprintf(sb, "Detected provider: %s\n", p.DisplayName)
+	}
+
+	if patch.LastPatchedByThisTool(b) {
+		sb.WriteString("Last patched by: bf2-migrator\n")
+	} else {
+		sb.WriteString("Last patched by: unknown\n")
+	}
+
+	sb.WriteString("\nSlots:\n")
+	sb.WriteString(patch.DumpSlots(b))
+}
+
+func writeRunningProvider(sb *strings.Builder) {
+	p, ok, err := patch.DetectRunningProvider()
+	if err != nil {
+		fmt.Fprintf(sb, "Running game provider: %s\n", err)
+	} else if !ok {
+		fmt.Fprintf(sb, "Running game provider: not %s\n", patch.BF2Hub.DisplayName)
+	} else {
+		fmt.Fprintf(sb, "Running game provider: %s\n", p.DisplayName)
+	}
+}
+
+func writeBF2HubRegistryState(sb *strings.Builder, r registryRepository) {
+	sb.WriteString("BF2Hub registry:\n")
+	err := r.OpenKey(registry.CURRENT_USER, patch.BF2HubRegistryPath, registry.QUERY_VALUE, func(key registry.Key) error {
+		for _, name := range []string{"hrpApplyOnStartup", "hrpInterval"} {
+			value, _, err := key.GetIntegerValue(name)
+			if err != nil {
+				fmt.Fprintf(sb, "  %s: %s\n", name, err)
+				continue
+			}
+			fmt.Fprintf(sb, "  %s: %d\n", name, value)
+		}
+		return nil
+	})
+	if err != nil {
+		if errors.Is(err, registry.ErrNotExist) {
+			sb.WriteString("  not installed\n")
+		} else {
+			fmt.Fprintf(sb, "  failed to read: %s\n", err)
+		}
+	}
+}
@@ -0,0 +1,47 @@
+package gui
+
+import (
+	"github.com/lxn/walk"
+	"github.com/lxn/walk/declarative"
+)
+
+// runTextDialog shows (potentially long) read-only text in a monospaced font, e.g. for tabular diagnostic output
+func runTextDialog(owner walk.Form, title string, text string) error {
+	var dlg *walk.Dialog
+	var closePB *walk.PushButton
+
+	_, err := declarative.Dialog{
+		AssignTo:      &dlg,
+		Title:         title,
+		DefaultButton: &closePB,
+		CancelButton:  &closePB,
+		MinSize:       declarative.Size{Width: 600, Height: 300},
+		Layout:        declarative.VBox{},
+		Children: []declarative.Widget{
+			declarative.TextEdit{
+				Text:     text,
+				ReadOnly: true,
+				VScroll:  true,
+				HScroll:  true,
+				Font:     declarative.Font{Family: "Consolas", PointSize: 9},
+			},
+			declarative.Composite{
+				Layout: declarative.HBox{
+					MarginsZero: true,
+				},
+				Children: []declarative.Widget{
+					declarative.HSpacer{},
+					declarative.PushButton{
+						AssignTo: &closePB,
+						Text:     "Close",
+						OnClicked: func() {
+							dlg.Accept()
+						},
+					},
+				},
+			},
+		},
+	}.Run(owner)
+
+	return err
+}
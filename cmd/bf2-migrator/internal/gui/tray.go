@@ -0,0 +1,95 @@
+package gui
+
+import (
+	"github.com/lxn/walk"
+	"github.com/lxn/win"
+)
+
+// trayAction is a quick action offered in the tray icon's context menu
+type trayAction struct {
+	Text        string
+	OnTriggered func()
+}
+
+// createTrayIcon adds an icon for the main window to the system tray. Its context menu offers the given actions
+// (each run with the window shown, since they may show dialogs), along with showing the window and quitting.
+func createTrayIcon(mw *walk.MainWindow, icon *walk.Icon, actions []trayAction) (*walk.NotifyIcon, error) {
+	ni, err := walk.NewNotifyIcon(mw)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = ni.SetIcon(icon); err != nil {
+		_ = ni.Dispose()
+		return nil, err
+	}
+	if err = ni.SetToolTip(WindowTitle); err != nil {
+		_ = ni.Dispose()
+		return nil, err
+	}
+
+	ni.MouseDown().Attach(func(x, y int, button walk.MouseButton) {
+		if button == walk.LeftButton {
+			restoreFromTray(mw)
+		}
+	})
+
+	items := make([]*walk.Action, 0, len(actions)+3)
+	for _, a := range actions {
+		a := a
+		action := walk.NewAction()
+		_ = action.SetText(a.Text)
+		action.Triggered().Attach(func() {
+			restoreFromTray(mw)
+			a.OnTriggered()
+		})
+		items = append(items, action)
+	}
+
+	show := walk.NewAction()
+	_ = show.SetText("Show window")
+	show.Triggered().Attach(func() {
+		restoreFromTray(mw)
+	})
+
+	quit := walk.NewAction()
+	_ = quit.SetText("Quit")
+	quit.Triggered().Attach(func() {
+		// Closing the window (rather than exiting right away) still runs any checks done before quitting
+		restoreFromTray(mw)
+		_ = mw.Close()
+	})
+
+	items = append(items, walk.NewSeparatorAction(), show, quit)
+	for _, item := range items {
+		if err = ni.ContextMenu().Actions().Add(item); err != nil {
+			_ = ni.Dispose()
+			return nil, err
+		}
+	}
+
+	if err = ni.SetVisible(true); err != nil {
+		_ = ni.Dispose()
+		return nil, err
+	}
+
+	return ni, nil
+}
+
+// restoreFromTray shows the window again after it was hidden when minimizing to the tray
+func restoreFromTray(mw *walk.MainWindow) {
+	mw.Show()
+	win.ShowWindow(mw.Handle(), win.SW_RESTORE)
+	win.SetForegroundWindow(mw.Handle())
+}
+
+// setMinimizable adds/removes the window's minimize button, which is only offered when minimizing to the tray
+func setMinimizable(hwnd win.HWND, enabled bool) {
+	style := win.GetWindowLong(hwnd, win.GWL_STYLE)
+	if enabled {
+		style |= win.WS_MINIMIZEBOX
+	} else {
+		style &^= win.WS_MINIMIZEBOX
+	}
+	win.SetWindowLong(hwnd, win.GWL_STYLE, style)
+}
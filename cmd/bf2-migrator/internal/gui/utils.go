@@ -1,72 +1,34 @@
 package gui
 
 import (
-	"bytes"
-	"fmt"
-	"os"
-	"syscall"
-	"time"
+	"strings"
 
-	"github.com/mitchellh/go-ps"
+	"github.com/cetteup/conman/pkg/game"
+	"github.com/lxn/win"
 )
 
-func killProcess(pid int) error {
-	proc, err := os.FindProcess(pid)
-	if err != nil {
-		return err
-	}
-	if err = proc.Signal(syscall.SIGKILL); err != nil {
-		return err
+func setAlwaysOnTop(hwnd win.HWND, enabled bool) {
+	insertAfter := win.HWND_NOTOPMOST
+	if enabled {
+		insertAfter = win.HWND_TOPMOST
 	}
 
-	return nil
+	win.SetWindowPos(hwnd, insertAfter, 0, 0, 0, 0, win.SWP_NOMOVE|win.SWP_NOSIZE)
 }
 
-func waitForProcessesToExit(processes map[int]string) error {
-	iterations := 0
-	for ; len(processes) > 0 && iterations < 5; iterations++ {
-		for pid := range processes {
-			proc, err := ps.FindProcess(pid)
-			if err != nil {
-				return fmt.Errorf("failed to check if killed process is still running: %s", err)
-			}
-
-			// Remove process from map if it exited (was no longer found)
-			if proc == nil {
-				delete(processes, pid)
-			}
-		}
-		time.Sleep(1 * time.Second)
+// filterProfiles returns all profiles whose name contains the given text (case-insensitive)
+func filterProfiles(profiles []game.Profile, text string) []game.Profile {
+	text = strings.ToLower(strings.TrimSpace(text))
+	if text == "" {
+		return profiles
 	}
 
-	// Return error if not all processes exited yet
-	if len(processes) > 0 {
-		return fmt.Errorf("timed out waiting for killed processes to exit")
-	}
-
-	return nil
-}
-
-func padRight(b []byte, c byte, l int) []byte {
-	if len(b) >= l {
-		return b
-	}
-
-	p := make([]byte, len(b), l)
-	copy(p, b)
-	for len(p) < l {
-		p = append(p, c)
-	}
-
-	return p
-}
-
-func containsAll(b []byte, subslices [][]byte) bool {
-	for _, subslice := range subslices {
-		if !bytes.Contains(b, subslice) {
-			return false
+	filtered := make([]game.Profile, 0, len(profiles))
+	for _, profile := range profiles {
+		if strings.Contains(strings.ToLower(profile.Name), text) {
+			filtered = append(filtered, profile)
 		}
 	}
 
-	return true
+	return filtered
 }
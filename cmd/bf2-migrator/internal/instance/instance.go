@@ -0,0 +1,31 @@
+package instance
+
+import (
+	"errors"
+
+	"golang.org/x/sys/windows"
+)
+
+var ErrAlreadyRunning = errors.New("another instance is already running")
+
+// Lock creates a named mutex which is held for as long as the process runs (or until the returned release func is
+// called), returning ErrAlreadyRunning if another process already holds a mutex of the same name
+func Lock(name string) (func(), error) {
+	n, err := windows.UTF16PtrFromString(name)
+	if err != nil {
+		return nil, err
+	}
+
+	handle, err := windows.CreateMutex(nil, false, n)
+	if err != nil {
+		if errors.Is(err, windows.ERROR_ALREADY_EXISTS) {
+			_ = windows.CloseHandle(handle)
+			return nil, ErrAlreadyRunning
+		}
+		return nil, err
+	}
+
+	return func() {
+		_ = windows.CloseHandle(handle)
+	}, nil
+}
@@ -0,0 +1,141 @@
+package migrate
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/cetteup/conman/pkg/game"
+)
+
+// DefaultBatchDelay is the default pause between migrating profiles in batch operations, to be polite to the OpenSpy
+// API even when not rate-limited
+const DefaultBatchDelay = time.Second
+
+// DefaultProfileDuration is a conservative estimate of how long migrating a single profile takes, for use if no
+// timings have been recorded yet
+const DefaultProfileDuration = 5 * time.Second
+
+// Pause waits for the given delay, returning early with the context's error if it is cancelled in the meantime
+func Pause(ctx context.Context, delay time.Duration) error {
+	if delay <= 0 {
+		return ctx.Err()
+	}
+
+	t := time.NewTimer(delay)
+	defer t.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
+
+// ProfileResult is the outcome of migrating a single profile as part of a batch
+type ProfileResult struct {
+	Profile game.Profile
+	Err     error
+	// Duration is the time migrating the profile took (excluding any pause before it)
+	Duration time.Duration
+}
+
+func (r ProfileResult) String() string {
+	if r.Err != nil {
+		return fmt.Sprintf("Profile %q: failed (%s)", r.Profile.Name, r.Err)
+	}
+
+	return fmt.Sprintf("Profile %q: migrated", r.Profile.Name)
+}
+
+// MigrateProfiles migrates the given profiles one after another, pausing for the given delay between profiles. If the
+// context is cancelled while pausing, the results of all profiles attempted so far are returned along with an error.
+func (t Title) MigrateProfiles(ctx context.Context, h game.Handler, c Client, profiles []game.Profile, delay time.Duration) ([]ProfileResult, error) {
+	results := make([]ProfileResult, 0, len(profiles))
+	for i, profile := range profiles {
+		if i > 0 {
+			if err := Pause(ctx, delay); err != nil {
+				return results, fmt.Errorf("cancelled before migrating %q: %w", profile.Name, err)
+			}
+		}
+
+		start := time.Now()
+		err := t.MigrateProfile(h, c, profile)
+		results = append(results, ProfileResult{
+			Profile:  profile,
+			Err:      err,
+			Duration: time.Since(start),
+		})
+	}
+
+	return results, nil
+}
+
+// AverageDuration returns the average time migrating a profile took across the given results, false if there are none
+func AverageDuration(results []ProfileResult) (time.Duration, bool) {
+	if len(results) == 0 {
+		return 0, false
+	}
+
+	var total time.Duration
+	for _, r := range results {
+		total += r.Duration
+	}
+
+	return total / time.Duration(len(results)), true
+}
+
+// EstimateBatch estimates how long migrating n profiles takes, given the time per profile and the pause between profiles
+func EstimateBatch(n int, perProfile time.Duration, delay time.Duration) time.Duration {
+	if n <= 0 {
+		return 0
+	}
+
+	return time.Duration(n)*perProfile + time.Duration(n-1)*delay
+}
+
+// FormatEstimate formats an estimated duration for display, e.g. "~2 minutes"
+func FormatEstimate(d time.Duration) string {
+	if d < time.Minute {
+		seconds := int(d.Round(time.Second) / time.Second)
+		if seconds <= 1 {
+			return "~1 second"
+		}
+		return fmt.Sprintf("~%d seconds", seconds)
+	}
+
+	minutes := int(d.Round(time.Minute) / time.Minute)
+	if minutes == 1 {
+		return "~1 minute"
+	}
+	return fmt.Sprintf("~%d minutes", minutes)
+}
+
+// FailedProfiles returns the profiles which could not be migrated
+func FailedProfiles(results []ProfileResult) []game.Profile {
+	failed := make([]game.Profile, 0, len(results))
+	for _, result := range results {
+		if result.Err != nil {
+			failed = append(failed, result.Profile)
+		}
+	}
+
+	return failed
+}
+
+// MergeResults replaces the results of any retried profiles with the results of the retry, keeping the original order
+func MergeResults(results []ProfileResult, retried []ProfileResult) []ProfileResult {
+	merged := make([]ProfileResult, len(results))
+	copy(merged, results)
+	for _, r := range retried {
+		for i := range merged {
+			if merged[i].Profile.Key == r.Profile.Key {
+				merged[i] = r
+				break
+			}
+		}
+	}
+
+	return merged
+}
@@ -0,0 +1,102 @@
+package migrate
+
+import (
+	"encoding/csv"
+	"io"
+	"net/mail"
+	"strconv"
+	"strings"
+
+	"github.com/cetteup/conman/pkg/game"
+)
+
+// Eligibility describes whether a profile can be migrated, determined without contacting OpenSpy
+type Eligibility struct {
+	Profile             game.Profile
+	Nick                string
+	Email               string
+	PasswordDecryptable bool
+	EmailValid          bool
+	// Problem explains why the profile cannot be migrated (empty if it can be)
+	Problem string
+}
+
+func (e Eligibility) Migratable() bool {
+	return e.Profile.Type == game.ProfileTypeMultiplayer && e.PasswordDecryptable && e.EmailValid
+}
+
+// CheckEligibility checks every requirement for migrating the profile, continuing past failed checks so all problems
+// are reported at once
+func (t Title) CheckEligibility(h game.Handler, profile game.Profile) Eligibility {
+	e := Eligibility{
+		Profile: profile,
+	}
+
+	if profile.Type != game.ProfileTypeMultiplayer {
+		e.Problem = "not a multiplayer profile"
+		return e
+	}
+
+	profileCon, err := t.readProfileCon(h, profile)
+	if err != nil {
+		e.Problem = err.Error()
+		return e
+	}
+
+	problems := make([]string, 0)
+	if nick, encrypted, err2 := t.GetEncryptedLogin(profileCon); err2 != nil {
+		problems = append(problems, "failed to get encrypted login")
+	} else {
+		e.Nick = nick
+		if _, err2 = t.DecryptPassword(encrypted); err2 != nil {
+			problems = append(problems, "password cannot be decrypted")
+		} else {
+			e.PasswordDecryptable = true
+		}
+	}
+
+	if email, err2 := profileCon.GetValue(t.EmailKey); err2 != nil {
+		problems = append(problems, "no email address")
+	} else {
+		e.Email = email.String()
+		// Email is used as is to log in to OpenSpy, so only accept plain addresses (no display name or other decorations)
+		if address, err3 := mail.ParseAddress(e.Email); err3 != nil || address.Address != e.Email {
+			problems = append(problems, "invalid email address")
+		} else {
+			e.EmailValid = true
+		}
+	}
+
+	e.Problem = strings.Join(problems, ", ")
+	return e
+}
+
+// WriteEligibilityCSV writes the results as CSV, one row per profile
+func WriteEligibilityCSV(w io.Writer, results []Eligibility) error {
+	cw := csv.NewWriter(w)
+	rows := [][]string{
+		{"Profile", "Type", "Nick", "Email", "Password decryptable", "Email valid", "Migratable", "Problem"},
+	}
+	for _, e := range results {
+		rows = append(rows, []string{
+			e.Profile.Name,
+			profileTypeName(e.Profile.Type),
+			e.Nick,
+			e.Email,
+			strconv.FormatBool(e.PasswordDecryptable),
+			strconv.FormatBool(e.EmailValid),
+			strconv.FormatBool(e.Migratable()),
+			e.Problem,
+		})
+	}
+
+	return cw.WriteAll(rows)
+}
+
+func profileTypeName(t game.ProfileType) string {
+	if t == game.ProfileTypeMultiplayer {
+		return "multiplayer"
+	}
+
+	return "singleplayer"
+}
@@ -0,0 +1,97 @@
+package migrate
+
+import (
+	"sort"
+	"strconv"
+
+	"github.com/cetteup/conman/pkg/game"
+	"github.com/rs/zerolog/log"
+)
+
+// readProfiles reads all profiles, ordered by key. Unlike bf2.GetProfiles, profiles which cannot be read (e.g. folders
+// created by other tools) are skipped instead of failing to read any profiles at all.
+func (t Title) readProfiles(h game.Handler) ([]game.Profile, error) {
+	keys, err := h.GetProfileKeys(t.Game)
+	if err != nil {
+		return nil, err
+	}
+
+	profiles := make([]game.Profile, 0, len(keys))
+	for _, key := range keys {
+		if key == t.TemplateProfileKey {
+			continue
+		}
+
+		profileCon, err2 := h.ReadProfileConfig(t.Game, key)
+		if err2 != nil {
+			log.Warn().
+				Err(err2).
+				Str("key", key).
+				Msg("Failed to read profile, skipping it")
+			continue
+		}
+
+		name, err2 := profileCon.GetValue(t.NameKey)
+		if err2 != nil {
+			log.Warn().
+				Err(err2).
+				Str("key", key).
+				Msg("Profile does not have a name, skipping it")
+			continue
+		}
+
+		profileType := game.ProfileTypeMultiplayer
+		// Singleplayer profiles do not contain an email address
+		if !profileCon.HasKey(t.EmailKey) {
+			profileType = game.ProfileTypeSingleplayer
+		}
+
+		profiles = append(profiles, game.Profile{
+			Key:  key,
+			Name: name.String(),
+			Type: profileType,
+		})
+	}
+
+	sortProfiles(profiles)
+
+	return profiles, nil
+}
+
+// sortProfiles orders profiles with numeric keys by their numeric value (so "1" and "0002" are ordered as the game
+// would), followed by any profiles with non-numeric keys in lexical order
+func sortProfiles(profiles []game.Profile) {
+	sort.SliceStable(profiles, func(i, j int) bool {
+		a, aErr := strconv.Atoi(profiles[i].Key)
+		b, bErr := strconv.Atoi(profiles[j].Key)
+		switch {
+		case aErr == nil && bErr == nil:
+			return a < b
+		case aErr == nil:
+			return true
+		case bErr == nil:
+			return false
+		default:
+			return profiles[i].Key < profiles[j].Key
+		}
+	})
+}
+
+// sameProfileKey checks whether both keys refer to the same profile, treating numeric keys with and without
+// zero-padding (e.g. "0001" and "1") as equal
+func sameProfileKey(a, b string) bool {
+	if a == b {
+		return true
+	}
+
+	x, err := strconv.Atoi(a)
+	if err != nil {
+		return false
+	}
+	y, err := strconv.Atoi(b)
+	if err != nil {
+		return false
+	}
+
+	return x == y
+}
@@ -0,0 +1,98 @@
+package migrate
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/cetteup/conman/pkg/game"
+
+	"github.com/cetteup/bf2-migrator/cmd/bf2-migrator/internal/profilesdir"
+)
+
+func TestGetProfiles(t *testing.T) {
+	profiles := map[string]string{
+		"spprofile": "LocalProfile.setName \"singleplayer\"\r\n",
+		"0003":      completeProfileCon,
+		"1":         completeProfileCon,
+		"Default":   "LocalProfile.setName \"Default\"\r\n",
+	}
+	wantKeys := []string{"1", "0003", "spprofile"}
+	wantTypes := []game.ProfileType{game.ProfileTypeMultiplayer, game.ProfileTypeMultiplayer, game.ProfileTypeSingleplayer}
+
+	tests := []struct {
+		name        string
+		defaultUser string
+		wantIndex   int
+	}{
+		{
+			name:        "default profile with zero-padded reference to non-padded folder",
+			defaultUser: "0001",
+			wantIndex:   0,
+		},
+		{
+			name:        "default profile with non-padded reference to zero-padded folder",
+			defaultUser: "3",
+			wantIndex:   1,
+		},
+		{
+			name:        "default profile does not exist",
+			defaultUser: "0002",
+			wantIndex:   0,
+		},
+		{
+			name:      "no default profile",
+			wantIndex: 0,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := newProfilesHandler(t, profiles)
+			if tt.defaultUser != "" {
+				globalCon := "GlobalSettings.setDefaultUser \"" + tt.defaultUser + "\"\r\n"
+				if err := os.WriteFile(filepath.Join(os.Getenv(profilesdir.EnvProfilesDir), "Global.con"), []byte(globalCon), 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			got, index, err := BF2.GetProfiles(h)
+			if err != nil {
+				t.Fatalf("expected no error, got %v", err)
+			}
+
+			if len(got) != len(wantKeys) {
+				t.Fatalf("expected %d profiles, got %d", len(wantKeys), len(got))
+			}
+			for i, p := range got {
+				if p.Key != wantKeys[i] || p.Type != wantTypes[i] {
+					t.Errorf("expected profile %d to be %q (type %v), got %q (type %v)", i, wantKeys[i], wantTypes[i], p.Key, p.Type)
+				}
+			}
+
+			if index != tt.wantIndex {
+				t.Errorf("expected index %d, got %d", tt.wantIndex, index)
+			}
+		})
+	}
+}
+
+func TestSameProfileKey(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want bool
+	}{
+		{a: "0001", b: "0001", want: true},
+		{a: "0001", b: "1", want: true},
+		{a: "3", b: "0003", want: true},
+		{a: "0001", b: "0002", want: false},
+		{a: "spprofile", b: "spprofile", want: true},
+		{a: "spprofile", b: "0001", want: false},
+	}
+
+	for _, tt := range tests {
+		if got := sameProfileKey(tt.a, tt.b); got != tt.want {
+			t.Errorf("sameProfileKey(%q, %q): expected %t, got %t", tt.a, tt.b, tt.want, got)
+		}
+	}
+}
@@ -0,0 +1,244 @@
+package migrate
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"strings"
+
+	"github.com/cetteup/conman/pkg/config"
+	"github.com/cetteup/conman/pkg/game"
+	"github.com/rs/zerolog/log"
+
+	api "github.com/cetteup/bf2-migrator/pkg/openspy"
+)
+
+type Client interface {
+	CreateAccount(email, password string, partnerCode int) error
+	CreateProfile(nick string, namespaceID int) error
+	GetProfiles() ([]api.ProfileDTO, error)
+}
+
+// GetProfiles returns all profiles along with the index of the profile to pre-select, which is the game's default
+// profile (as set in Global.con) or, if that cannot be determined, the first multiplayer profile
+func (t Title) GetProfiles(h game.Handler) ([]game.Profile, int, error) {
+	profiles, err := t.readProfiles(h)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	// Reads the default profile reference from Global.con
+	defaultProfileKey, err := t.GetDefaultProfileKey(h)
+	if err != nil {
+		log.Error().
+			Err(err).
+			Msg("Failed to get default profile key")
+		// If determining the default profile fails, fall back to pre-selecting a profile (don't return an error)
+		return profiles, fallbackProfileIndex(profiles), nil
+	}
+
+	for i, profile := range profiles {
+		if sameProfileKey(profile.Key, defaultProfileKey) {
+			return profiles, i, nil
+		}
+	}
+
+	// Global.con may reference a profile which no longer exists (e.g. after deleting a profile outside the game)
+	log.Warn().
+		Str("key", defaultProfileKey).
+		Msg("Default profile referenced in Global.con does not exist")
+
+	return profiles, fallbackProfileIndex(profiles), nil
+}
+
+// fallbackProfileIndex returns the index of the first multiplayer profile, since only those can be migrated
+func fallbackProfileIndex(profiles []game.Profile) int {
+	for i, profile := range profiles {
+		if profile.Type == game.ProfileTypeMultiplayer {
+			return i
+		}
+	}
+
+	return 0
+}
+
+// ErrAccountHasOtherNicks indicates that a profile was not created, since the OpenSpy account already has profiles using
+// other nicks and may thus belong to someone else (see OtherNicks)
+var ErrAccountHasOtherNicks = errors.New("OpenSpy account already has profiles using other nicks and may belong to someone else")
+
+// MigrateProfile creates the OpenSpy account and profile for the given profile without asking for any confirmation. If
+// the account already has profiles using other nicks, it returns ErrAccountHasOtherNicks instead of creating the profile.
+func (t Title) MigrateProfile(h game.Handler, c Client, profile game.Profile) error {
+	nick, existing, err := t.FindExistingProfiles(h, c, profile)
+	if err != nil {
+		return err
+	}
+
+	return t.createProfileUnconfirmed(c, nick, existing)
+}
+
+// createProfileUnconfirmed creates the profile unless it already exists, refusing to add it to an account which may
+// belong to someone else, since nobody has confirmed that the account is theirs
+func (t Title) createProfileUnconfirmed(c Client, nick string, existing []api.ProfileDTO) error {
+	if others := t.OtherNicks(existing, nick); len(others) > 0 && !t.HasProfile(existing, nick) {
+		return fmt.Errorf("%w (%s), migrate %q interactively to confirm the account is yours", ErrAccountHasOtherNicks, strings.Join(others, ", "), nick)
+	}
+
+	_, err := t.CreateProfileUnlessExists(c, nick, existing)
+	return err
+}
+
+// FindExistingProfiles authenticates using the profile's login details and returns the profile's nick along with all
+// profiles of the OpenSpy account (in any namespace). Since OpenSpy only offers logging in by registering, the account is
+// created as a side effect if it does not exist yet.
+func (t Title) FindExistingProfiles(h game.Handler, c Client, profile game.Profile) (string, []api.ProfileDTO, error) {
+	nick, err := t.Authenticate(h, c, profile)
+	if err != nil {
+		return "", nil, err
+	}
+
+	existing, err := c.GetProfiles()
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to get OpenSpy account profiles: %w", err)
+	}
+
+	return nick, existing, nil
+}
+
+// CreateProfileUnlessExists creates the OpenSpy profile for the title unless one of the existing profiles
+// already uses the nick in the title's namespace, returning whether a profile was created
+func (t Title) CreateProfileUnlessExists(c Client, nick string, existing []api.ProfileDTO) (bool, error) {
+	if t.HasProfile(existing, nick) {
+		return false, nil
+	}
+
+	if err := c.CreateProfile(nick, t.NamespaceID); err != nil {
+		return false, fmt.Errorf("failed to create OpenSpy profile: %w", err)
+	}
+
+	return true, nil
+}
+
+// HasProfile returns true if the profiles contain a profile using the nick in the title's namespace
+func (t Title) HasProfile(profiles []api.ProfileDTO, nick string) bool {
+	// Don't use slices package here to maintain compatibility with go 1.20 (and thus Windows 7)
+	for _, profile := range profiles {
+		if profile.UniqueNick == nick && profile.NamespaceID == t.NamespaceID {
+			return true
+		}
+	}
+
+	return false
+}
+
+// OtherNicks returns the nicks of all profiles of the title other than the given nick. Any such profile indicates that
+// the account existed before and may belong to someone else (e.g. if the email address is shared or was mistyped).
+func (t Title) OtherNicks(profiles []api.ProfileDTO, nick string) []string {
+	others := make([]string, 0)
+	for _, profile := range profiles {
+		if profile.UniqueNick != nick && profile.NamespaceID == t.NamespaceID {
+			others = append(others, profile.UniqueNick)
+		}
+	}
+
+	return others
+}
+
+// DescribeExistingProfiles returns a human-readable description of which OpenSpy profiles already exist on the account
+// (which FindExistingProfiles will have created already, if it did not exist before)
+func (t Title) DescribeExistingProfiles(nick string, existing []api.ProfileDTO) string {
+	// Make clear that declining only skips creating the profile, it does not undo creating the account
+	const account = "The OpenSpy account has already been set up (created, unless it existed before)."
+	if t.HasProfile(existing, nick) {
+		return fmt.Sprintf("%s\n\nA %s profile named %q already exists on the account, no profile will be created.", account, t.DisplayName, nick)
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "%s\n\nNo %s profile named %q exists on the account yet, it will be created.", account, t.DisplayName, nick)
+	if others := t.OtherNicks(existing, nick); len(others) > 0 {
+		fmt.Fprintf(&sb, "\n\nWarning: The account already has %s profile(s) named %s. Please make sure the account is yours before adding %q to it.", t.DisplayName, strings.Join(others, ", "), nick)
+	}
+	for _, p := range existing {
+		if p.UniqueNick == nick {
+			fmt.Fprintf(&sb, "\n\nNote: The account already has a profile named %q in namespace %d (another game).", p.UniqueNick, p.NamespaceID)
+		}
+	}
+
+	return sb.String()
+}
+
+// Authenticate creates the OpenSpy account using the profile's login details (or logs in to the account if it already
+// exists), returning the profile's nick
+func (t Title) Authenticate(h game.Handler, c Client, profile game.Profile) (string, error) {
+	profileCon, err := t.readProfileCon(h, profile)
+	if err != nil {
+		return "", err
+	}
+
+	// An empty or partially written profile.con would otherwise fail further down with errors about missing keys
+	if err = t.validateProfileCon(profileCon); err != nil {
+		return "", fmt.Errorf("profile config file of %q is empty or corrupt (%s), please recreate the profile in-game", profile.Name, err)
+	}
+
+	nick, encrypted, err := t.GetEncryptedLogin(profileCon)
+	if err != nil {
+		return "", fmt.Errorf("failed to get encrypted login from profile config file: %w", err)
+	}
+
+	password, err := t.DecryptPassword(encrypted)
+	if err != nil {
+		return "", fmt.Errorf("failed to decrypt profile password: %w", err)
+	}
+
+	email, err := profileCon.GetValue(t.EmailKey)
+	if err != nil {
+		return "", fmt.Errorf("failed to get email address from profile config file: %w", err)
+	}
+
+	err = c.CreateAccount(email.String(), password, 0)
+	if err != nil {
+		return "", fmt.Errorf("failed to create OpenSpy account: %w", err)
+	}
+
+	return nick, nil
+}
+
+// readProfileCon reads the profile's profile.con, explaining the common causes of failing to do so
+func (t Title) readProfileCon(h game.Handler, profile game.Profile) (*config.Config, error) {
+	profileCon, err := h.ReadProfileConfig(t.Game, profile.Key)
+	if err != nil {
+		if errors.Is(err, fs.ErrPermission) {
+			// Usually caused by the profile being located in another Windows user's documents folder
+			return nil, fmt.Errorf("access to profile config file of %q was denied, please run the tool as the Windows user the profile belongs to or as administrator: %w", profile.Name, err)
+		}
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, fmt.Errorf("profile config file of %q does not exist, please recreate the profile in-game: %w", profile.Name, err)
+		}
+		return nil, fmt.Errorf("failed to read profile config file: %w", err)
+	}
+
+	return profileCon, nil
+}
+
+// validateProfileCon makes sure all values required for migrating are present and non-empty. profile.con files don't
+// contain any checksum, so this is the best available way to detect empty, partially written or tampered files.
+func (t Title) validateProfileCon(profileCon *config.Config) error {
+	missing := make([]string, 0)
+	for _, key := range t.RequiredKeys {
+		if !profileCon.HasKey(key) {
+			missing = append(missing, key)
+			continue
+		}
+
+		value, err := profileCon.GetValue(key)
+		if err != nil || strings.Trim(value.String(), "\" ") == "" {
+			missing = append(missing, key)
+		}
+	}
+
+	if len(missing) > 0 {
+		return fmt.Errorf("missing or empty values: %s", strings.Join(missing, ", "))
+	}
+
+	return nil
+}
@@ -0,0 +1,193 @@
+package migrate
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/cetteup/conman/pkg/config"
+	"github.com/cetteup/conman/pkg/game"
+	"github.com/cetteup/conman/pkg/handler"
+	filerepo "github.com/cetteup/filerepo/pkg"
+
+	"github.com/cetteup/bf2-migrator/cmd/bf2-migrator/internal/profilesdir"
+	api "github.com/cetteup/bf2-migrator/pkg/openspy"
+)
+
+const completeProfileCon = "LocalProfile.setName \"mister249\"\r\n" +
+	"LocalProfile.setGamespyNick \"mister249\"\r\n" +
+	"LocalProfile.setEmail \"mister249@example.com\"\r\n" +
+	"LocalProfile.setPassword 01000000D08C9DDF0115D1118C7A00C04FC297EB\r\n"
+
+func TestValidateProfileCon(t *testing.T) {
+	tests := []struct {
+		name        string
+		content     string
+		wantMissing []string
+	}{
+		{
+			name:    "complete",
+			content: completeProfileCon,
+		},
+		{
+			name:        "zero bytes",
+			content:     "",
+			wantMissing: []string{"LocalProfile.setGamespyNick", "LocalProfile.setPassword", "LocalProfile.setEmail"},
+		},
+		{
+			name:        "only line breaks",
+			content:     "\r\n\r\n",
+			wantMissing: []string{"LocalProfile.setGamespyNick", "LocalProfile.setPassword", "LocalProfile.setEmail"},
+		},
+		{
+			name:        "partially written",
+			content:     completeProfileCon[:strings.Index(completeProfileCon, "LocalProfile.setEmail")],
+			wantMissing: []string{"LocalProfile.setPassword", "LocalProfile.setEmail"},
+		},
+		{
+			name:        "truncated within key",
+			content:     completeProfileCon[:strings.Index(completeProfileCon, "LocalProfile.setPassword")+16],
+			wantMissing: []string{"LocalProfile.setPassword"},
+		},
+		{
+			name:        "empty quoted value",
+			content:     strings.Replace(completeProfileCon, "LocalProfile.setGamespyNick \"mister249\"", "LocalProfile.setGamespyNick \"\"", 1),
+			wantMissing: []string{"LocalProfile.setGamespyNick"},
+		},
+		{
+			name:        "blank value",
+			content:     strings.Replace(completeProfileCon, "\"mister249@example.com\"", "  ", 1),
+			wantMissing: []string{"LocalProfile.setEmail"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := BF2.validateProfileCon(config.FromBytes("Profile.con", []byte(tt.content)))
+			if len(tt.wantMissing) == 0 {
+				if err != nil {
+					t.Fatalf("expected no error, got %v", err)
+				}
+				return
+			}
+
+			if err == nil {
+				t.Fatalf("expected error about missing %v, got nil", tt.wantMissing)
+			}
+			want := "missing or empty values: " + strings.Join(tt.wantMissing, ", ")
+			if err.Error() != want {
+				t.Errorf("expected error %q, got %q", want, err.Error())
+			}
+		})
+	}
+}
+
+// newProfilesHandler writes the given profile.con contents (keyed by profile folder) to a temporary profiles folder
+// and returns a handler reading profiles from it
+func newProfilesHandler(t *testing.T, profiles map[string]string) game.Handler {
+	t.Helper()
+
+	dir := t.TempDir()
+	for key, content := range profiles {
+		if err := os.MkdirAll(filepath.Join(dir, key), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, key, "Profile.con"), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	t.Setenv(profilesdir.EnvProfilesDir, dir)
+	h, err := profilesdir.FromEnv(handler.New(filerepo.New()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return h
+}
+
+// clientStub records the nicks of all profiles created
+type clientStub struct {
+	created []string
+}
+
+func (c *clientStub) CreateAccount(_, _ string, _ int) error {
+	return nil
+}
+
+func (c *clientStub) CreateProfile(nick string, _ int) error {
+	c.created = append(c.created, nick)
+	return nil
+}
+
+func (c *clientStub) GetProfiles() ([]api.ProfileDTO, error) {
+	return nil, nil
+}
+
+func TestCreateProfileUnconfirmed(t *testing.T) {
+	namespaceID := BF2.NamespaceID
+
+	tests := []struct {
+		name        string
+		existing    []api.ProfileDTO
+		wantCreated bool
+		wantErr     error
+	}{
+		{
+			name:        "new account",
+			wantCreated: true,
+		},
+		{
+			name:     "profile exists",
+			existing: []api.ProfileDTO{{UniqueNick: "mister249", NamespaceID: namespaceID}},
+		},
+		{
+			name:     "profile exists along with other nicks",
+			existing: []api.ProfileDTO{{UniqueNick: "mister249", NamespaceID: namespaceID}, {UniqueNick: "mister250", NamespaceID: namespaceID}},
+		},
+		{
+			name:     "only other nicks",
+			existing: []api.ProfileDTO{{UniqueNick: "mister250", NamespaceID: namespaceID}},
+			wantErr:  ErrAccountHasOtherNicks,
+		},
+		{
+			name:        "other nicks in another namespace",
+			existing:    []api.ProfileDTO{{UniqueNick: "mister250", NamespaceID: namespaceID + 1}},
+			wantCreated: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &clientStub{}
+			if err := BF2.createProfileUnconfirmed(c, "mister249", tt.existing); !errors.Is(err, tt.wantErr) {
+				t.Errorf("expected %v, got %v", tt.wantErr, err)
+			}
+
+			if created := len(c.created) > 0; created != tt.wantCreated {
+				t.Errorf("expected profile to be created: %t, got %t", tt.wantCreated, created)
+			}
+		})
+	}
+}
+
+func TestTitlesAreIndependent(t *testing.T) {
+	// Another title using the same profiles, but a different namespace
+	other := BF2
+	other.Name = "other"
+	other.NamespaceID = BF2.NamespaceID + 1
+
+	existing := []api.ProfileDTO{{UniqueNick: "mister249", NamespaceID: BF2.NamespaceID}}
+
+	if !BF2.HasProfile(existing, "mister249") {
+		t.Errorf("expected %s to have profile", BF2.Name)
+	}
+	if other.HasProfile(existing, "mister249") {
+		t.Errorf("expected %s not to have profile", other.Name)
+	}
+	if others := other.OtherNicks(existing, "mister250"); len(others) != 0 {
+		t.Errorf("expected no other nicks for %s, got %v", other.Name, others)
+	}
+}
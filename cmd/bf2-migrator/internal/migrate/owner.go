@@ -0,0 +1,46 @@
+package migrate
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"github.com/cetteup/conman/pkg/game"
+	"golang.org/x/sys/windows"
+)
+
+// CheckProfileOwner determines the Windows user owning the profile's folder, returning the owner's account name and
+// whether that is the current user. Folders owned by groups (e.g. Administrators, if created elevated) are treated as
+// owned by the current user, since they cannot be attributed to another person.
+func (t Title) CheckProfileOwner(h game.Handler, profile game.Profile) (string, bool, error) {
+	profileCon, err := t.readProfileCon(h, profile)
+	if err != nil {
+		return "", false, err
+	}
+
+	sd, err := windows.GetNamedSecurityInfo(filepath.Dir(profileCon.Path), windows.SE_FILE_OBJECT, windows.OWNER_SECURITY_INFORMATION)
+	if err != nil {
+		return "", false, fmt.Errorf("failed to get security info of profile folder: %w", err)
+	}
+
+	owner, _, err := sd.Owner()
+	if err != nil {
+		return "", false, fmt.Errorf("failed to get owner of profile folder: %w", err)
+	}
+
+	user, err := windows.GetCurrentProcessToken().GetTokenUser()
+	if err != nil {
+		return "", false, fmt.Errorf("failed to get current user: %w", err)
+	}
+
+	account, domain, accountType, err := owner.LookupAccount("")
+	if err != nil {
+		return "", false, fmt.Errorf("failed to look up owner of profile folder: %w", err)
+	}
+
+	name := account
+	if domain != "" {
+		name = domain + "\\" + account
+	}
+
+	return name, accountType != windows.SidTypeUser || owner.Equals(user.User.Sid), nil
+}
@@ -0,0 +1,69 @@
+package migrate
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/cetteup/conman/pkg/config"
+	"github.com/cetteup/conman/pkg/game"
+)
+
+// UpdateProfilePassword encrypts the given password the same way the game does and stores it in the profile's
+// profile.con, e.g. to keep the in-game auto-login working after changing the password
+func (t Title) UpdateProfilePassword(h game.Handler, profile game.Profile, password string) error {
+	if profile.Type != game.ProfileTypeMultiplayer {
+		return fmt.Errorf("profile %q is not a multiplayer profile", profile.Name)
+	}
+
+	profileCon, err := t.readProfileCon(h, profile)
+	if err != nil {
+		return err
+	}
+
+	encrypted, err := t.EncryptPassword(password)
+	if err != nil {
+		return fmt.Errorf("failed to encrypt profile password: %w", err)
+	}
+
+	profileCon.SetValue(t.PasswordKey, *config.NewValue(encrypted))
+
+	if err = writeFileAtomically(profileCon.Path, profileCon.ToBytes()); err != nil {
+		return fmt.Errorf("failed to write profile config file: %w", err)
+	}
+
+	return nil
+}
+
+// writeFileAtomically writes to a temporary file first and then replaces the target, so the target is never left
+// partially written
+func writeFileAtomically(path string, data []byte) error {
+	stats, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		// Clean up if anything failed before the rename (is a no-op after a successful rename)
+		_ = os.Remove(tmp.Name())
+	}()
+
+	if _, err = tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+
+	if err = tmp.Close(); err != nil {
+		return err
+	}
+
+	if err = os.Chmod(tmp.Name(), stats.Mode()); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), path)
+}
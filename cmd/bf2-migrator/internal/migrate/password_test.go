@@ -0,0 +1,62 @@
+package migrate
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/cetteup/conman/pkg/game"
+)
+
+func TestUpdateProfilePassword(t *testing.T) {
+	h := newProfilesHandler(t, map[string]string{"0001": completeProfileCon})
+	profile := game.Profile{Key: "0001", Name: "mister249", Type: game.ProfileTypeMultiplayer}
+
+	if err := BF2.UpdateProfilePassword(h, profile, "n3w-p4ssw0rd"); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	profileCon, err := BF2.readProfileCon(h, profile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The stored password needs to decrypt to exactly the one that was set
+	nick, encrypted, err := BF2.GetEncryptedLogin(profileCon)
+	if err != nil {
+		t.Fatal(err)
+	}
+	password, err := BF2.DecryptPassword(encrypted)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if password != "n3w-p4ssw0rd" {
+		t.Errorf("expected decrypted password %q, got %q", "n3w-p4ssw0rd", password)
+	}
+
+	// Nothing else should have been changed
+	if nick != "mister249" {
+		t.Errorf("expected nick %q, got %q", "mister249", nick)
+	}
+	if err = BF2.validateProfileCon(profileCon); err != nil {
+		t.Errorf("expected profile.con to still be complete, got %v", err)
+	}
+
+	// No temporary files should be left behind
+	entries, err := os.ReadDir(filepath.Dir(profileCon.Path))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Errorf("expected only Profile.con in profile folder, found %d entries", len(entries))
+	}
+}
+
+func TestUpdateProfilePasswordSingleplayer(t *testing.T) {
+	h := newProfilesHandler(t, map[string]string{"0001": "LocalProfile.setName \"offline\"\r\n"})
+	profile := game.Profile{Key: "0001", Name: "offline", Type: game.ProfileTypeSingleplayer}
+
+	if err := BF2.UpdateProfilePassword(h, profile, "n3w-p4ssw0rd"); err == nil {
+		t.Fatal("expected error for singleplayer profile, got nil")
+	}
+}
@@ -0,0 +1,67 @@
+package migrate
+
+import (
+	"fmt"
+
+	"github.com/cetteup/conman/pkg/game"
+)
+
+// ProfilePlan describes what migrating a profile would do, determined without contacting OpenSpy
+type ProfilePlan struct {
+	Profile game.Profile
+	Nick    string
+	Email   string
+	Err     error
+}
+
+func (p ProfilePlan) String() string {
+	if p.Err != nil {
+		return fmt.Sprintf("Profile %q: cannot be migrated (%s)", p.Profile.Name, p.Err)
+	}
+
+	return fmt.Sprintf("Profile %q: create (or log in to) account %s, create profile %q unless it already exists", p.Profile.Name, p.Email, p.Nick)
+}
+
+// PlanProfile checks whether a profile can be migrated by reading (but not using) its login details
+func (t Title) PlanProfile(h game.Handler, profile game.Profile) ProfilePlan {
+	plan := ProfilePlan{
+		Profile: profile,
+	}
+
+	if profile.Type != game.ProfileTypeMultiplayer {
+		plan.Err = fmt.Errorf("not a multiplayer profile")
+		return plan
+	}
+
+	profileCon, err := t.readProfileCon(h, profile)
+	if err != nil {
+		plan.Err = err
+		return plan
+	}
+
+	if err = t.validateProfileCon(profileCon); err != nil {
+		plan.Err = fmt.Errorf("profile config file is empty or corrupt (%s)", err)
+		return plan
+	}
+
+	nick, encrypted, err := t.GetEncryptedLogin(profileCon)
+	if err != nil {
+		plan.Err = fmt.Errorf("failed to get encrypted login from profile config file: %w", err)
+		return plan
+	}
+	plan.Nick = nick
+
+	if _, err = t.DecryptPassword(encrypted); err != nil {
+		plan.Err = fmt.Errorf("failed to decrypt profile password: %w", err)
+		return plan
+	}
+
+	email, err := profileCon.GetValue(t.EmailKey)
+	if err != nil {
+		plan.Err = fmt.Errorf("failed to get email address from profile config file: %w", err)
+		return plan
+	}
+	plan.Email = email.String()
+
+	return plan
+}
@@ -0,0 +1,79 @@
+package migrate
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/cetteup/conman/pkg/config"
+	"github.com/cetteup/conman/pkg/game"
+	"github.com/cetteup/conman/pkg/game/bf2"
+	"github.com/cetteup/conman/pkg/handler"
+)
+
+// Title describes a game whose profiles can be migrated to OpenSpy. Everything title-specific (where profiles are
+// stored, how the login is read from them and which OpenSpy namespace they belong to) is defined here, so the rest of
+// the migration machinery works the same for any title.
+//
+// Title is the extension point for supporting other games: every title-specific operation (reading profiles,
+// migrating them, ...) is a method of the title it works on, so callers always state which title they mean and
+// several titles can be used side by side. Only Battlefield 2 is supported for now. To add another title, define a
+// Title backed by the title's conman package (the same way BF2 uses conman's bf2 package) and add it to Titles, which
+// makes it selectable via the -title flag.
+type Title struct {
+	// Name is the title's identifier (e.g. used to select the title via the -title flag)
+	Name string
+	// DisplayName is the user-facing name to be used in labels and messages
+	DisplayName string
+	// Game is the conman game used to locate the title's profiles
+	Game handler.Game
+	// NamespaceID is the OpenSpy namespace used by the title
+	NamespaceID int
+	// TemplateProfileKey is the key of the profile the game uses as a template for new profiles, which is not a real
+	// profile and thus never listed
+	TemplateProfileKey string
+	// NameKey, EmailKey and PasswordKey are the keys of the respective values in the profile's config file
+	NameKey     string
+	EmailKey    string
+	PasswordKey string
+	// RequiredKeys are the keys of all values in the profile's config file which are required for migrating
+	RequiredKeys []string
+	// GetDefaultProfileKey returns the key of the profile the game uses by default
+	GetDefaultProfileKey func(h game.Handler) (string, error)
+	// GetEncryptedLogin returns the nick and encrypted password stored in the profile's config file
+	GetEncryptedLogin func(c *config.Config) (string, string, error)
+	// DecryptPassword and EncryptPassword convert between plain passwords and the form stored in the config file
+	DecryptPassword func(encrypted string) (string, error)
+	EncryptPassword func(password string) (string, error)
+}
+
+var BF2 = Title{
+	Name:                 "bf2",
+	DisplayName:          "Battlefield 2",
+	Game:                 handler.GameBf2,
+	NamespaceID:          12,
+	TemplateProfileKey:   bf2.DefaultProfileKey,
+	NameKey:              bf2.ProfileConKeyName,
+	EmailKey:             bf2.ProfileConKeyEmail,
+	PasswordKey:          bf2.ProfileConKeyPassword,
+	RequiredKeys:         []string{bf2.ProfileConKeyGamespyNick, bf2.ProfileConKeyPassword, bf2.ProfileConKeyEmail},
+	GetDefaultProfileKey: bf2.GetDefaultProfileKey,
+	GetEncryptedLogin:    bf2.GetEncryptedLogin,
+	DecryptPassword:      bf2.DecryptProfileConPassword,
+	EncryptPassword:      bf2.EncryptProfileConPassword,
+}
+
+// Titles contains all titles whose profiles can be migrated
+var Titles = []Title{BF2}
+
+// FindTitle returns the title with the given name (see Title.Name), ignoring case
+func FindTitle(name string) (Title, error) {
+	names := make([]string, 0, len(Titles))
+	for _, t := range Titles {
+		if strings.EqualFold(t.Name, name) {
+			return t, nil
+		}
+		names = append(names, t.Name)
+	}
+
+	return Title{}, fmt.Errorf("unknown title %q, must be one of: %s", name, strings.Join(names, ", "))
+}
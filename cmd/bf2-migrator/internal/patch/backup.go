@@ -0,0 +1,249 @@
+package patch
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	backupSuffix = ".bak"
+	// unrecognizedBackupName is used in place of the provider name for backups of binaries not using a known provider
+	unrecognizedBackupName = "unrecognized"
+)
+
+// BackupPath returns the path of the backup of the binary in the given dir from while it used the given provider. Each
+// provider gets a backup of its own, so switching between providers does not overwrite earlier states. Binaries not
+// using a known provider (zero value) share a single backup.
+func BackupPath(dir string, p Provider) string {
+	name := p.Name
+	if name == "" {
+		name = unrecognizedBackupName
+	}
+
+	return filepath.Join(dir, BF2ExecutableName+backupSuffix+"."+strings.ToLower(name))
+}
+
+// backupPaths returns the paths of all backups in the given dir, including those named the way older versions did
+// (e.g. BF2.exe.bak)
+func backupPaths(dir string) ([]string, error) {
+	patterns := []string{
+		filepath.Join(dir, BF2ExecutableName+"*"+backupSuffix),
+		filepath.Join(dir, BF2ExecutableName+backupSuffix+".*"),
+	}
+
+	paths := make([]string, 0)
+	for _, pattern := range patterns {
+		matches, err := filepath.Glob(pattern)
+		if err != nil {
+			return nil, err
+		}
+		paths = append(paths, matches...)
+	}
+
+	return paths, nil
+}
+
+func HasBackup(dir string) bool {
+	paths, err := backupPaths(dir)
+	return err == nil && len(paths) > 0
+}
+
+func createBackup(dir string, b []byte, mode os.FileMode, p Provider) (string, error) {
+	path := BackupPath(dir, p)
+	return path, os.WriteFile(path, b, mode)
+}
+
+// RestoreBackup replaces the binary with the newest backup using a known provider, which is the state from before it
+// was last patched
+func RestoreBackup(r RegistryRepository, dir string, skipBF2HubRegistry bool) error {
+	backups, err := ListBackups(dir)
+	if err != nil {
+		return err
+	}
+
+	// Backups are only created of binaries using a known provider, anything else indicates the backup was modified
+	for _, backup := range backups {
+		if backup.Provider.Name != "" {
+			_, err = ImportBackup(r, dir, backup.Path, skipBF2HubRegistry)
+			return err
+		}
+	}
+
+	return fmt.Errorf("no backup using a known provider found")
+}
+
+// ImportBackup replaces the binary in the given dir with the given backup (e.g. one copied from another machine),
+// returning the provider used by the imported binary. Like patching, it prepares for patching (see PrepareForPatch)
+// and backs up the binary being replaced before writing.
+func ImportBackup(r RegistryRepository, dir string, backupPath string, skipBF2HubRegistry bool) (Provider, error) {
+	if dir == "" {
+		return Provider{}, ErrNoInstallDir
+	}
+
+	b, err := os.ReadFile(backupPath)
+	if err != nil {
+		return Provider{}, fmt.Errorf("failed to read backup: %w", err)
+	}
+
+	// Anything not using a known provider is either not a BF2 binary at all or contains modifications
+	p, err := DetermineCurrentlyUsedProvider(b)
+	if err != nil {
+		return Provider{}, fmt.Errorf("backup is not a recognized %s: %w", BF2ExecutableName, err)
+	}
+
+	if _, err = PrepareForPatch(r, skipBF2HubRegistry); err != nil {
+		return Provider{}, fmt.Errorf("failed to prepare for importing backup: %w", err)
+	}
+
+	// Binary may be missing entirely if the install is broken
+	path := filepath.Join(dir, BF2ExecutableName)
+	mode := os.FileMode(0o755)
+	var original []byte
+	if stats, err2 := os.Stat(path); err2 == nil {
+		mode = stats.Mode()
+		if original, err = os.ReadFile(path); err != nil {
+			return Provider{}, err
+		}
+	} else if !errors.Is(err2, fs.ErrNotExist) {
+		return Provider{}, err2
+	}
+
+	// Check before writing anything, since running out of space mid-way would leave a corrupted binary behind
+	if err = ensureFreeSpace(dir, uint64(len(original)+len(b))); err != nil {
+		return Provider{}, err
+	}
+
+	// Keep the binary being replaced, unless there is none (or it is the same as the backup anyway)
+	if original != nil && !bytes.Equal(original, b) {
+		// Errors are not relevant here, binaries not using a known provider get a backup of their own
+		current, _ := DetermineCurrentlyUsedProvider(original)
+		if _, err = createBackup(dir, original, mode, current); err != nil {
+			return Provider{}, fmt.Errorf("failed to create backup: %w", err)
+		}
+	}
+
+	if err = os.WriteFile(path, b, mode); err != nil {
+		return Provider{}, err
+	}
+
+	return p, nil
+}
+
+type Backup struct {
+	Path    string
+	ModTime time.Time
+	Size    int64
+	// Hash is the hex-encoded SHA-256 hash of the backup's content
+	Hash string
+	// Provider is the provider used by the backup, zero value if it does not use a known provider
+	Provider Provider
+}
+
+// ListBackups returns all backups of the binary in the given dir, newest first
+func ListBackups(dir string) ([]Backup, error) {
+	paths, err := backupPaths(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	backups := make([]Backup, 0, len(paths))
+	for _, path := range paths {
+		stats, err2 := os.Stat(path)
+		if err2 != nil {
+			return nil, err2
+		}
+		if stats.IsDir() {
+			continue
+		}
+
+		b, err2 := os.ReadFile(path)
+		if err2 != nil {
+			return nil, fmt.Errorf("failed to read backup %s: %w", filepath.Base(path), err2)
+		}
+
+		sum := sha256.Sum256(b)
+		// Errors are not relevant here, any backup not using a known provider is simply not usable
+		p, _ := DetermineCurrentlyUsedProvider(b)
+
+		backups = append(backups, Backup{
+			Path:     path,
+			ModTime:  stats.ModTime(),
+			Size:     stats.Size(),
+			Hash:     hex.EncodeToString(sum[:]),
+			Provider: p,
+		})
+	}
+
+	sort.Slice(backups, func(i, j int) bool {
+		return backups[i].ModTime.After(backups[j].ModTime)
+	})
+
+	return backups, nil
+}
+
+// DisposableBackups returns all backups older than maxAge which are not needed to restore the binary. The newest
+// valid backup (one which uses a known provider) is always kept, unless its content is identical to the binary.
+func DisposableBackups(dir string, backups []Backup, maxAge time.Duration, now time.Time) ([]Backup, error) {
+	current, err := hashFile(filepath.Join(dir, BF2ExecutableName))
+	if err != nil {
+		return nil, err
+	}
+
+	keep := ""
+	for _, backup := range backups {
+		if backup.Hash != current && backup.Provider.Name != "" {
+			keep = backup.Path
+			break
+		}
+	}
+
+	disposable := make([]Backup, 0, len(backups))
+	for _, backup := range backups {
+		if backup.Path == keep || now.Sub(backup.ModTime) < maxAge {
+			continue
+		}
+		disposable = append(disposable, backup)
+	}
+
+	return disposable, nil
+}
+
+// DeleteBackups deletes the given backups, skipping any backup whose content changed since it was listed
+func DeleteBackups(backups []Backup) ([]Backup, error) {
+	deleted := make([]Backup, 0, len(backups))
+	for _, backup := range backups {
+		hash, err := hashFile(backup.Path)
+		if err != nil {
+			return deleted, fmt.Errorf("failed to hash backup %s: %w", filepath.Base(backup.Path), err)
+		}
+		if hash != backup.Hash {
+			continue
+		}
+
+		if err = os.Remove(backup.Path); err != nil {
+			return deleted, fmt.Errorf("failed to delete backup %s: %w", filepath.Base(backup.Path), err)
+		}
+		deleted = append(deleted, backup)
+	}
+
+	return deleted, nil
+}
+
+func hashFile(path string) (string, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:]), nil
+}
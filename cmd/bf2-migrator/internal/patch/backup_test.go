@@ -0,0 +1,89 @@
+package patch
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestImportBackup(t *testing.T) {
+	tests := []struct {
+		name       string
+		current    []byte
+		imported   []byte
+		wantBackup string
+		wantErr    bool
+	}{
+		{
+			name:       "binary using a known provider",
+			current:    fixtureFor(OpenSpy),
+			imported:   fixtureFor(GameSpy),
+			wantBackup: BF2ExecutableName + ".bak.openspy",
+		},
+		{
+			name:       "binary not using a known provider",
+			current:    []byte("not a BF2 binary"),
+			imported:   fixtureFor(GameSpy),
+			wantBackup: BF2ExecutableName + ".bak.unrecognized",
+		},
+		{
+			name:     "missing binary",
+			imported: fixtureFor(GameSpy),
+		},
+		{
+			name:     "backup not using a known provider",
+			current:  fixtureFor(OpenSpy),
+			imported: []byte("not a BF2 binary"),
+			wantErr:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			if tt.current != nil {
+				dir = writeFixture(t, tt.current)
+			}
+			backupPath := filepath.Join(t.TempDir(), "imported.bak")
+			if err := os.WriteFile(backupPath, tt.imported, 0o644); err != nil {
+				t.Fatal(err)
+			}
+
+			_, err := ImportBackup(registryStub{}, dir, backupPath, false)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("expected error, got nil")
+				}
+				if b := readFixture(t, dir); !bytes.Equal(b, tt.current) {
+					t.Errorf("expected binary to be unchanged")
+				}
+				if HasBackup(dir) {
+					t.Errorf("expected no backup to be created")
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("expected no error, got %v", err)
+			}
+			if b := readFixture(t, dir); !bytes.Equal(b, tt.imported) {
+				t.Errorf("expected binary to equal imported backup")
+			}
+
+			if tt.wantBackup == "" {
+				if HasBackup(dir) {
+					t.Errorf("expected no backup to be created")
+				}
+				return
+			}
+			backup, err := os.ReadFile(filepath.Join(dir, tt.wantBackup))
+			if err != nil {
+				t.Fatalf("expected backup, got %v", err)
+			}
+			if !bytes.Equal(backup, tt.current) {
+				t.Errorf("expected backup to equal binary from before importing")
+			}
+		})
+	}
+}
@@ -0,0 +1,29 @@
+package patch
+
+import (
+	"errors"
+	"fmt"
+
+	"golang.org/x/sys/windows"
+)
+
+var ErrInsufficientDiskSpace = errors.New("not enough disk space to safely patch")
+
+// ensureFreeSpace checks whether the volume containing the given dir has at least the given number of bytes available
+func ensureFreeSpace(dir string, required uint64) error {
+	path, err := windows.UTF16PtrFromString(dir)
+	if err != nil {
+		return err
+	}
+
+	var available uint64
+	if err = windows.GetDiskFreeSpaceEx(path, &available, nil, nil); err != nil {
+		return fmt.Errorf("failed to determine free disk space: %w", err)
+	}
+
+	if available < required {
+		return fmt.Errorf("%w (%d MB required, %d MB available)", ErrInsufficientDiskSpace, required/1024/1024, available/1024/1024)
+	}
+
+	return nil
+}
@@ -0,0 +1,84 @@
+package patch
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+)
+
+// ByteChange is a contiguous range of bytes changed by patching, with old and new bytes hex-encoded
+type ByteChange struct {
+	Offset int    `json:"offset"`
+	Old    string `json:"old"`
+	New    string `json:"new"`
+}
+
+// Script describes the exact byte changes required to patch a specific binary, allowing to apply them with other tools
+type Script struct {
+	File string `json:"file"`
+	From string `json:"from"`
+	To   string `json:"to"`
+	Size int    `json:"size"`
+	// SHA256Before and SHA256After allow verifying the binary before/after applying the changes
+	SHA256Before string       `json:"sha256Before"`
+	SHA256After  string       `json:"sha256After"`
+	Changes      []ByteChange `json:"changes"`
+}
+
+// ExportScript computes the byte changes required to patch the binary in the given dir to the given provider
+// without changing anything
+func ExportScript(dir string, new Provider) (Script, error) {
+	original, err := os.ReadFile(filepath.Join(dir, BF2ExecutableName))
+	if err != nil {
+		return Script{}, err
+	}
+
+	old, err := DetermineCurrentlyUsedProvider(original)
+	if err != nil {
+		return Script{}, err
+	}
+
+	modified := original
+	if new.Name != old.Name {
+		modified, err = ApplyModifications(original, old, new)
+		if err != nil {
+			return Script{}, err
+		}
+	}
+
+	before := sha256.Sum256(original)
+	after := sha256.Sum256(modified)
+
+	return Script{
+		File:         BF2ExecutableName,
+		From:         old.Name,
+		To:           new.Name,
+		Size:         len(original),
+		SHA256Before: hex.EncodeToString(before[:]),
+		SHA256After:  hex.EncodeToString(after[:]),
+		Changes:      diffBytes(original, modified),
+	}, nil
+}
+
+// diffBytes returns all contiguous ranges of bytes which differ between a and b (which must be of the same length)
+func diffBytes(a, b []byte) []ByteChange {
+	changes := make([]ByteChange, 0)
+	for i := 0; i < len(a); i++ {
+		if a[i] == b[i] {
+			continue
+		}
+
+		start := i
+		for i < len(a) && a[i] != b[i] {
+			i++
+		}
+		changes = append(changes, ByteChange{
+			Offset: start,
+			Old:    hex.EncodeToString(a[start:i]),
+			New:    hex.EncodeToString(b[start:i]),
+		})
+	}
+
+	return changes
+}
@@ -0,0 +1,142 @@
+package patch
+
+import (
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"golang.org/x/sys/windows/registry"
+)
+
+// fixtureSlotLengths are the lengths of the provider-specific slots contained in a BF2 1.5 binary, in the order the
+// values are listed in fixtureValues
+var fixtureSlotLengths = []int{18, 21, 21, 56, 19, 30, 24, 21, 16, 16, 19, 10}
+
+// fixtureValues are the slot values of a BF2 1.5 binary using each provider, as written by the respective patcher.
+// They are deliberately spelled out rather than derived from the modifications, so tests catch mistakes in those.
+var fixtureValues = map[string][]string{
+	GameSpy.Name: {
+		"\\drivers\\etc\\hosts",
+		"gamestats.gamespy.com",
+		"gamestats.gamespy.com",
+		"http://stage-net.gamespy.com/bf2/getplayerinfo.aspx?pid=",
+		"BF2Web.gamespy.com",
+		"http://BF2Web.gamespy.com/ASP/",
+		"%s.available.gamespy.com",
+		"%s.master.gamespy.com",
+		"gpcm.gamespy.com",
+		"gpsp.gamespy.com",
+		"%s.ms%d.gamespy.com",
+		"WS2_32.dll",
+	},
+	BF2Hub.Name: {
+		"\\drivers\\xtc\\hosts",
+		"gamestats.gamespy.com",
+		"gamestats.gamespy.com",
+		"http://stage-net.gamespy.com/bf2/getplayerinfo.aspx?pid=",
+		"BF2Web.gamespy.com",
+		"http://BF2Web.gamespy.com/ASP/",
+		"%s.available.gamespy.com",
+		"%s.master.gamespy.com",
+		"gpcm.gamespy.com",
+		"gpsp.gamespy.com",
+		"%s.ms%d.gamespy.com",
+		"bf2hbc.dll",
+	},
+	PlayBF2.Name: {
+		"\\drivers\\etc\\hasts",
+		"gamestats.playbf2.ru",
+		"gamestats.playbf2.ru",
+		"http://stage-net.playbf2.ru/bf2/getplayerinfo.aspx?pid=",
+		"BF2Web.playbf2.ru",
+		"http://BF2Web.playbf2.ru/ASP/",
+		"%s.available.playbf2.ru",
+		"%s.master.playbf2.ru",
+		"gpcm.playbf2.ru",
+		"gpsp.playbf2.ru",
+		"%s.ms.playbf2.ru",
+		"WS2_32.dll",
+	},
+	OpenSpy.Name: {
+		"\\drivers\\etz\\hosts",
+		"gamestats.openspy.net",
+		"gamestats.openspy.net",
+		"http://stage-net.openspy.net/bf2/getplayerinfo.aspx?pid=",
+		"BF2Web.openspy.net",
+		"http://BF2Web.openspy.net/ASP/",
+		"%s.available.openspy.net",
+		"%s.master.openspy.net",
+		"gpcm.openspy.net",
+		"gpsp.openspy.net",
+		"%s.ms%d.openspy.net",
+		"WS2_32.dll",
+	},
+}
+
+// buildFixture returns a fake binary containing the given slot values, each padded to the slot length and separated
+// by unrelated (non-nil) bytes, followed by a version resource for BF2 1.5
+func buildFixture(values []string) []byte {
+	var b []byte
+	for i, v := range values {
+		b = append(b, padRight([]byte(v), 0, fixtureSlotLengths[i])...)
+		b = append(b, 0, 0xcc, 0xcc, 0xcc)
+	}
+
+	// VS_FIXEDFILEINFO signature, structure version, file version 1.5.3153.0
+	info := make([]byte, 16)
+	copy(info, fixedFileInfoSignature)
+	binary.LittleEndian.PutUint32(info[4:], 0x00010000)
+	binary.LittleEndian.PutUint32(info[8:], 1<<16|5)
+	binary.LittleEndian.PutUint32(info[12:], 3153<<16)
+	b = append(b, info...)
+
+	return b
+}
+
+// fixtureFor returns a fake binary using the given provider
+func fixtureFor(p Provider) []byte {
+	return buildFixture(fixtureValues[p.Name])
+}
+
+// writeFixture writes the binary to a temporary install dir and returns the dir
+func writeFixture(t *testing.T, b []byte) string {
+	t.Helper()
+
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, BF2ExecutableName), b, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	return dir
+}
+
+// readFixture reads the binary from the given install dir
+func readFixture(t *testing.T, dir string) []byte {
+	t.Helper()
+
+	b, err := os.ReadFile(filepath.Join(dir, BF2ExecutableName))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return b
+}
+
+// registryStub is a registry without any keys, as on a system without BF2Hub
+type registryStub struct{}
+
+func (registryStub) OpenKey(_ registry.Key, _ string, _ uint32, _ func(key registry.Key) error) error {
+	return registry.ErrNotExist
+}
+
+// registrySpy is a registryStub which counts how often any key was opened
+type registrySpy struct {
+	registryStub
+	opened int
+}
+
+func (r *registrySpy) OpenKey(k registry.Key, path string, access uint32, cb func(key registry.Key) error) error {
+	r.opened++
+	return r.registryStub.OpenKey(k, path, access, cb)
+}
@@ -0,0 +1,125 @@
+package patch
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"github.com/cetteup/joinme.click-launcher/pkg/software_finder"
+	"golang.org/x/sys/windows/registry"
+)
+
+// ErrNoInstallDir indicates that no install directory was given, e.g. because a finder returned an empty path without
+// an error. An empty dir must never be used, since joining it with the binary name yields a relative path.
+var ErrNoInstallDir = errors.New("could not determine install directory")
+
+type Finder interface {
+	GetInstallDirFromSomewhere(configs []software_finder.Config) (string, error)
+}
+
+// Copied from https://github.com/cetteup/joinme.click-launcher/blob/089fb595adc426aab775fe40165431501a5c38c3/internal/titles/bf2.go#L37
+var installDirConfigs = []software_finder.Config{
+	{
+		ForType:           software_finder.RegistryFinder,
+		RegistryKey:       software_finder.RegistryKeyLocalMachine,
+		RegistryPath:      bf2RegistryPathFor(runtime.GOARCH),
+		RegistryValueName: "InstallDir",
+	},
+	{
+		ForType:           software_finder.RegistryFinder,
+		RegistryKey:       software_finder.RegistryKeyCurrentUser,
+		RegistryPath:      "SOFTWARE\\BF2Hub Systems\\BF2Hub Client",
+		RegistryValueName: "bf2Dir",
+	},
+}
+
+func DetectInstallPath(f Finder) (string, error) {
+	dirs, err := DetectInstallPaths(f)
+	if err != nil {
+		return "", err
+	}
+
+	return dirs[0], nil
+}
+
+// DetectInstallPaths returns all distinct install directories referenced by any finder config, in order of the configs
+func DetectInstallPaths(f Finder) ([]string, error) {
+	// Try configs one by one, since registry values may be left behind after moving/removing the install
+	// (and the finder does not check whether the directory it returns actually exists)
+	dirs := make([]string, 0, len(installDirConfigs))
+	failures := make([]string, 0, len(installDirConfigs))
+	for _, c := range installDirConfigs {
+		dir, err := f.GetInstallDirFromSomewhere([]software_finder.Config{c})
+		if err == nil {
+			err = checkInstallDir(dir)
+		}
+		if err != nil {
+			failures = append(failures, fmt.Sprintf("%s: %s", describeConfig(c), err.Error()))
+			continue
+		}
+
+		if !containsPath(dirs, dir) {
+			dirs = append(dirs, dir)
+		}
+	}
+
+	if len(dirs) == 0 {
+		return nil, fmt.Errorf("failed to determine Battlefield 2 install directory (%s)", strings.Join(failures, "; "))
+	}
+
+	return dirs, nil
+}
+
+// containsPath checks whether paths contain the given path, comparing them the way Windows does (case-insensitive)
+func containsPath(paths []string, path string) bool {
+	for _, p := range paths {
+		if strings.EqualFold(filepath.Clean(p), filepath.Clean(path)) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func checkInstallDir(dir string) error {
+	if dir == "" {
+		return ErrNoInstallDir
+	}
+
+	stats, err := os.Stat(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return fmt.Errorf("directory %q does not exist", dir)
+		}
+		return err
+	}
+
+	if !stats.IsDir() {
+		return fmt.Errorf("%q is not a directory", dir)
+	}
+
+	return nil
+}
+
+// registryKeyNames are the common abbreviations of the registry's root keys
+var registryKeyNames = map[software_finder.RegistryKey]string{
+	software_finder.RegistryKey(registry.CLASSES_ROOT):   "HKCR",
+	software_finder.RegistryKeyCurrentUser:               "HKCU",
+	software_finder.RegistryKeyLocalMachine:              "HKLM",
+	software_finder.RegistryKey(registry.USERS):          "HKU",
+	software_finder.RegistryKey(registry.CURRENT_CONFIG): "HKCC",
+}
+
+// describeConfig returns a human-readable description of a (registry) finder config
+func describeConfig(c software_finder.Config) string {
+	key, ok := registryKeyNames[c.RegistryKey]
+	if !ok {
+		// Not a root key, so there is no name to refer to it by
+		key = fmt.Sprintf("%#x", uint64(c.RegistryKey))
+	}
+
+	return fmt.Sprintf("%s\\%s\\%s", key, c.RegistryPath, c.RegistryValueName)
+}
@@ -0,0 +1,94 @@
+package patch
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/cetteup/joinme.click-launcher/pkg/software_finder"
+)
+
+// finderStub returns the dir configured for the config's registry path, or an empty dir without an error
+type finderStub map[string]string
+
+func (f finderStub) GetInstallDirFromSomewhere(configs []software_finder.Config) (string, error) {
+	return f[configs[0].RegistryPath], nil
+}
+
+func TestDetectInstallPath(t *testing.T) {
+	dir := t.TempDir()
+
+	tests := []struct {
+		name    string
+		finder  finderStub
+		want    string
+		wantErr error
+	}{
+		{
+			name:    "empty dir from every config",
+			finder:  finderStub{},
+			wantErr: ErrNoInstallDir,
+		},
+		{
+			name:   "empty dir from first config",
+			finder: finderStub{installDirConfigs[1].RegistryPath: dir},
+			want:   dir,
+		},
+		{
+			name:   "dir from first config",
+			finder: finderStub{installDirConfigs[0].RegistryPath: dir},
+			want:   dir,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := DetectInstallPath(tt.finder)
+			if tt.wantErr != nil {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr.Error()) {
+					t.Fatalf("expected error about %q, got %v", tt.wantErr, err)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("expected no error, got %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("expected %q, got %q", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestPatchBinaryEmptyDir(t *testing.T) {
+	if _, err := PatchBinary("", OpenSpy); !errors.Is(err, ErrNoInstallDir) {
+		t.Errorf("expected %v, got %v", ErrNoInstallDir, err)
+	}
+}
+
+func TestDescribeConfig(t *testing.T) {
+	tests := []struct {
+		name string
+		key  software_finder.RegistryKey
+		want string
+	}{
+		{name: "local machine", key: software_finder.RegistryKeyLocalMachine, want: `HKLM\SOFTWARE\Electronic Arts\EA Games\Battlefield 2\InstallDir`},
+		{name: "current user", key: software_finder.RegistryKeyCurrentUser, want: `HKCU\SOFTWARE\Electronic Arts\EA Games\Battlefield 2\InstallDir`},
+		{name: "non-root key", key: software_finder.RegistryKey(0x1234), want: `0x1234\SOFTWARE\Electronic Arts\EA Games\Battlefield 2\InstallDir`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := describeConfig(software_finder.Config{
+				ForType:           software_finder.RegistryFinder,
+				RegistryKey:       tt.key,
+				RegistryPath:      `SOFTWARE\Electronic Arts\EA Games\Battlefield 2`,
+				RegistryValueName: "InstallDir",
+			})
+			if got != tt.want {
+				t.Errorf("expected %q, got %q", tt.want, got)
+			}
+		})
+	}
+}
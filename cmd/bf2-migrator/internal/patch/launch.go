@@ -0,0 +1,24 @@
+package patch
+
+import (
+	"fmt"
+	"os/exec"
+	"path/filepath"
+)
+
+// LaunchGame starts the game from the given dir without waiting for it to exit. Binaries with unknown modifications
+// are not launched, since they would likely not work anyway.
+func LaunchGame(dir string) error {
+	if _, err := DetectProvider(dir); err != nil {
+		return fmt.Errorf("refusing to launch binary in unknown state: %w", err)
+	}
+
+	cmd := exec.Command(filepath.Join(dir, BF2ExecutableName), "+menu", "1", "+fullscreen", "1")
+	// Game needs to be started from its install dir in order to find its files
+	cmd.Dir = dir
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+
+	return cmd.Process.Release()
+}
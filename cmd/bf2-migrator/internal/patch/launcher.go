@@ -0,0 +1,36 @@
+package patch
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/mitchellh/go-ps"
+)
+
+// launcherExecutableNames contains executables of launchers known to (re-)launch the game, e.g. right after
+// PrepareForPatch closed it
+var launcherExecutableNames = map[string]string{
+	"origin.exe":     "Origin",
+	"eadesktop.exe":  "EA app",
+	"gameranger.exe": "GameRanger",
+}
+
+// DetectRunningLaunchers returns the names of all running launchers which might relaunch the game while patching
+func DetectRunningLaunchers() ([]string, error) {
+	processes, err := ps.Processes()
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve process list: %s", err)
+	}
+
+	seen := map[string]bool{}
+	launchers := make([]string, 0)
+	for _, process := range processes {
+		name, ok := launcherExecutableNames[strings.ToLower(process.Executable())]
+		if ok && !seen[name] {
+			seen[name] = true
+			launchers = append(launchers, name)
+		}
+	}
+
+	return launchers, nil
+}
@@ -0,0 +1,83 @@
+package patch
+
+import (
+	"bytes"
+	"encoding/binary"
+)
+
+const (
+	markerPrefix = "bf2-migrator:"
+	// markerLength is the number of bytes reserved for the marker (prefix plus provider name, padded with nil-bytes)
+	markerLength = 32
+)
+
+// markerOffset returns the offset of the marker slot at the end of the PE header padding (the unused space between
+// the section table and the first section). The padding is never read by Windows or the game, so writing to it does
+// not affect the binary's behavior. Only returns a slot if it is unused or already contains a marker.
+func markerOffset(b []byte) (int, bool) {
+	if len(b) < 0x40 || !bytes.HasPrefix(b, []byte("MZ")) {
+		return 0, false
+	}
+
+	peOffset := int(binary.LittleEndian.Uint32(b[0x3c:]))
+	coffOffset := peOffset + 4
+	optionalOffset := coffOffset + 20
+	if peOffset < 0 || optionalOffset+64 > len(b) || !bytes.Equal(b[peOffset:coffOffset], []byte("PE\x00\x00")) {
+		return 0, false
+	}
+
+	sections := int(binary.LittleEndian.Uint16(b[coffOffset+2:]))
+	optionalSize := int(binary.LittleEndian.Uint16(b[coffOffset+16:]))
+	// SizeOfHeaders is located at the same offset in both PE32 and PE32+ optional headers
+	headersSize := int(binary.LittleEndian.Uint32(b[optionalOffset+60:]))
+	sectionTableEnd := optionalOffset + optionalSize + sections*40
+
+	offset := headersSize - markerLength
+	if offset < sectionTableEnd || headersSize > len(b) {
+		return 0, false
+	}
+
+	slot := b[offset:headersSize]
+	if !isZero(slot) && !bytes.HasPrefix(slot, []byte(markerPrefix)) {
+		return 0, false
+	}
+
+	return offset, true
+}
+
+// setMarker returns a copy of the binary marked as patched to the given provider by this tool. Since a GameSpy binary
+// is stock, the marker is removed instead in that case. Returns the offset of the marker slot, if any was found.
+func setMarker(b []byte, p Provider) ([]byte, int, bool) {
+	offset, ok := markerOffset(b)
+	if !ok {
+		return b, 0, false
+	}
+
+	marked := make([]byte, len(b))
+	copy(marked, b)
+
+	value := make([]byte, markerLength)
+	if p.Name != GameSpy.Name {
+		copy(value, markerPrefix+p.Name)
+	}
+	copy(marked[offset:offset+markerLength], value)
+
+	return marked, offset, true
+}
+
+// LastPatchedByThisTool checks whether the binary was last patched by this tool, meaning it contains a marker for the
+// provider it currently uses. Any other patcher touching the binary afterwards leaves a marker for another provider.
+func LastPatchedByThisTool(b []byte) bool {
+	offset, ok := markerOffset(b)
+	if !ok {
+		return false
+	}
+
+	p, err := DetermineCurrentlyUsedProvider(b)
+	if err != nil {
+		return false
+	}
+
+	value := bytes.TrimRight(b[offset:offset+markerLength], "\x00")
+	return string(value) == markerPrefix+p.Name
+}
@@ -0,0 +1,121 @@
+package patch
+
+import (
+	"bytes"
+)
+
+type modification struct {
+	Old    []byte
+	New    []byte
+	Length int
+	Count  int
+	// KeepSuffix indicates that Old/New only cover the start of the value (e.g. scheme and hostname of a url) and that
+	// whatever follows in the binary (e.g. the path) should be preserved
+	KeepSuffix bool
+	// IgnoreCase indicates that the old value may appear in any casing (e.g. for case-insensitive Windows paths),
+	// the new value will always be written as is
+	IgnoreCase bool
+}
+
+// step describes the modification as the i-th (0-based) of total modifications
+func (m modification) step(i int, total int) Step {
+	return Step{
+		Index: i + 1,
+		Total: total,
+		Old:   string(m.Old),
+		New:   string(m.New),
+		Count: m.Count,
+	}
+}
+
+// count returns the number of occurrences of the old value in b
+func (m modification) count(b []byte) int {
+	if m.IgnoreCase {
+		return len(indexAllFold(b, padRight(m.Old, 0, m.Length)))
+	}
+
+	if !m.KeepSuffix {
+		return bytes.Count(b, padRight(m.Old, 0, m.Length))
+	}
+
+	return len(m.findSlots(b))
+}
+
+// offsets returns the offsets of all occurrences of the old value in b, each covering Length bytes
+func (m modification) offsets(b []byte) []int {
+	if m.IgnoreCase {
+		return indexAllFold(b, padRight(m.Old, 0, m.Length))
+	}
+
+	if !m.KeepSuffix {
+		return indexAll(b, padRight(m.Old, 0, m.Length))
+	}
+
+	return m.findSlots(b)
+}
+
+// apply replaces all occurrences of the old value in b with the new value, keeping the length of b unchanged
+func (m modification) apply(b []byte) []byte {
+	if m.IgnoreCase {
+		modified := make([]byte, len(b))
+		copy(modified, b)
+		n := padRight(m.New, 0, m.Length)
+		for _, i := range indexAllFold(b, padRight(m.Old, 0, m.Length)) {
+			copy(modified[i:i+m.Length], n)
+		}
+		return modified
+	}
+
+	if !m.KeepSuffix {
+		return bytes.ReplaceAll(b, padRight(m.Old, 0, m.Length), padRight(m.New, 0, m.Length))
+	}
+
+	modified := make([]byte, len(b))
+	copy(modified, b)
+	for _, i := range m.findSlots(b) {
+		slot := b[i : i+m.Length+1]
+		suffix := slot[len(m.Old):bytes.IndexByte(slot, 0)]
+		value := append(append([]byte{}, m.New...), suffix...)
+		copy(modified[i:i+m.Length], padRight(value, 0, m.Length))
+	}
+
+	return modified
+}
+
+// findSlots returns the offsets of all slots starting with the old value, followed by a path and nil-bytes up to the
+// slot length (plus a terminating nil-byte if the value fills the entire slot). Slots are only considered if the new
+// value would fit as well.
+func (m modification) findSlots(b []byte) []int {
+	var offsets []int
+	for start := 0; start < len(b); {
+		i := bytes.Index(b[start:], m.Old)
+		if i == -1 {
+			break
+		}
+		i += start
+		start = i + len(m.Old)
+
+		if i+m.Length+1 > len(b) {
+			continue
+		}
+
+		slot := b[i : i+m.Length+1]
+		end := bytes.IndexByte(slot, 0)
+		if end == -1 || end == len(m.Old) || slot[len(m.Old)] != '/' {
+			continue
+		}
+
+		// Anything other than nil-bytes after the terminator would belong to another value
+		if !isZero(slot[end:]) {
+			continue
+		}
+
+		if len(m.New)+end-len(m.Old) > m.Length {
+			continue
+		}
+
+		offsets = append(offsets, i)
+	}
+
+	return offsets
+}
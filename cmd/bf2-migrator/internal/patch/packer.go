@@ -0,0 +1,56 @@
+package patch
+
+import (
+	"bytes"
+	"debug/pe"
+	"fmt"
+	"strings"
+)
+
+var ErrPackedBinary = fmt.Errorf("%s appears to be packed/compressed and cannot be patched in this state, restore an unpacked copy first", BF2ExecutableName)
+
+// packerSections maps (lower case) names of sections added by common executable packers to the packer's name
+var packerSections = map[string]string{
+	"upx0":      "UPX",
+	"upx1":      "UPX",
+	"upx2":      "UPX",
+	".mpress1":  "MPRESS",
+	".mpress2":  "MPRESS",
+	".aspack":   "ASPack",
+	"pec2":      "PECompact",
+	"pecompact": "PECompact",
+	".petite":   "Petite",
+	".nsp0":     "NsPack",
+	".nsp1":     "NsPack",
+	"kkrunchy":  "kkrunchy",
+}
+
+// upxMagic is contained in the header UPX writes to any binary it packed, even if the sections were renamed
+var upxMagic = []byte("UPX!")
+
+// detectPacker checks whether the binary was packed/compressed by a common executable packer, returning its name.
+// Packed binaries do not contain any provider-specific values in plain text, so they cannot be patched (or detected).
+func detectPacker(b []byte) (string, bool) {
+	f, err := pe.NewFile(bytes.NewReader(b))
+	if err != nil {
+		return "", false
+	}
+	defer f.Close()
+
+	for _, s := range f.Sections {
+		if name, ok := packerSections[strings.ToLower(s.Name)]; ok {
+			return name, true
+		}
+	}
+
+	// UPX stores its header right before the first packed section's data, so only look at the start of the binary
+	head := b
+	if len(head) > 4096 {
+		head = head[:4096]
+	}
+	if bytes.Contains(head, upxMagic) {
+		return "UPX", true
+	}
+
+	return "", false
+}
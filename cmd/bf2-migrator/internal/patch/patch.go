@@ -0,0 +1,414 @@
+package patch
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+var (
+	ErrUnknownModifications = errors.New("binary contains unknown/mixed modifications, revert changes first")
+	ErrAborted              = errors.New("patching aborted by user, no changes were made")
+)
+
+// Step describes a single modification about to be applied when patching interactively
+type Step struct {
+	// Index is the 1-based position of the modification among all Total modifications
+	Index int
+	Total int
+	Old   string
+	New   string
+	Count int
+}
+
+func (s Step) String() string {
+	return fmt.Sprintf("Step %d of %d: about to change %s → %s, %d occurrence(s)", s.Index, s.Total, s.Old, s.New, s.Count)
+}
+
+// ConfirmFunc is called before each modification is applied when patching interactively, returning false aborts patching
+type ConfirmFunc func(s Step) bool
+
+// Result describes the outcome of patching a binary
+type Result struct {
+	From Provider
+	To   Provider
+	// NoOp is true if the binary already used the target provider and was thus not modified
+	NoOp            bool
+	BackupPath      string
+	ModifiedOffsets []int
+	// Undo captures the binary from before patching, zero value if the binary was not modified
+	Undo UndoState
+}
+
+func (r Result) String() string {
+	if r.NoOp {
+		return fmt.Sprintf("Already using %s, no changes made", r.To.DisplayName)
+	}
+
+	verb := "Patched"
+	if r.To.Name == GameSpy.Name {
+		verb = "Reverted"
+	}
+
+	return fmt.Sprintf("%s %s from %s to %s (%d changes), backup at %s", verb, BF2ExecutableName, r.From.DisplayName, r.To.DisplayName, len(r.ModifiedOffsets), r.BackupPath)
+}
+
+// PatchBinary patches the binary in the given dir to use the new provider
+func PatchBinary(dir string, new Provider) (Result, error) {
+	return PatchBinaryInteractive(dir, new, nil)
+}
+
+// RevertBinaryInteractive reverts the binary in the given dir to stock (GameSpy), regardless of which provider it
+// currently uses. Reverting a binary which already uses GameSpy is a no-op.
+func RevertBinaryInteractive(dir string, confirm ConfirmFunc) (Result, error) {
+	return PatchBinaryInteractive(dir, GameSpy, confirm)
+}
+
+// PatchBinaryInteractive patches the binary in the given dir to use the new provider, asking confirm before applying
+// each modification (unless confirm is nil). Nothing is written unless every modification was confirmed.
+func PatchBinaryInteractive(dir string, new Provider, confirm ConfirmFunc) (Result, error) {
+	if dir == "" {
+		return Result{}, ErrNoInstallDir
+	}
+
+	path := filepath.Join(dir, BF2ExecutableName)
+
+	stats, err := os.Stat(path)
+	if err != nil {
+		return Result{}, err
+	}
+
+	original, err := os.ReadFile(path)
+	if err != nil {
+		return Result{}, err
+	}
+
+	// Detect "old"/current provider based on what's in the binary
+	old, err := DetermineCurrentlyUsedProvider(original)
+	if err != nil {
+		return Result{}, err
+	}
+
+	result := Result{
+		From: old,
+		To:   new,
+	}
+
+	// No need to patch if binary is already patched as desired
+	if new.Name == old.Name {
+		result.NoOp = true
+		return result, nil
+	}
+
+	modified, err := applyModifications(original, old, new, confirm)
+	if err != nil {
+		return result, err
+	}
+
+	// Check before writing anything, since running out of space mid-way would leave a corrupted binary behind
+	if err = ensureFreeSpace(dir, uint64(len(original)+len(modified))); err != nil {
+		return result, err
+	}
+
+	if result.BackupPath, err = createBackup(dir, original, stats.Mode(), old); err != nil {
+		return result, fmt.Errorf("failed to create backup: %w", err)
+	}
+
+	if err = os.WriteFile(path, modified, stats.Mode()); err != nil {
+		return result, err
+	}
+
+	for _, c := range diffBytes(original, modified) {
+		result.ModifiedOffsets = append(result.ModifiedOffsets, c.Offset)
+	}
+	result.Undo = newUndoState(dir, original, modified)
+
+	return result, nil
+}
+
+// ApplyModifications returns a copy of the binary in data switched from one provider to another, without any filesystem
+// access. The binary must currently use the from provider.
+func ApplyModifications(data []byte, from, to Provider) ([]byte, error) {
+	return applyModifications(data, from, to, nil)
+}
+
+func applyModifications(data []byte, from, to Provider, confirm ConfirmFunc) ([]byte, error) {
+	current, err := DetermineCurrentlyUsedProvider(data)
+	if err != nil {
+		return nil, err
+	}
+	if current.Name != from.Name {
+		return nil, fmt.Errorf("binary uses %s, not %s", current.DisplayName, from.DisplayName)
+	}
+
+	if from.Name == to.Name {
+		unchanged := make([]byte, len(data))
+		copy(unchanged, data)
+		return unchanged, nil
+	}
+
+	return modifyBinary(data, from, to, confirm)
+}
+
+// modificationsFor returns the modifications modifyBinary applies, only ever replaced in tests to inject faulty ones
+var modificationsFor = getModifications
+
+// modifyBinary returns a copy of the binary with all modifications required to switch from the old to the new provider.
+// If confirm is not nil, it is called before applying each modification.
+func modifyBinary(original []byte, old, new Provider, confirm ConfirmFunc) ([]byte, error) {
+	modifications := modificationsFor(old, new)
+	modified := original[:]
+	// Byte ranges (start, end) the modifications are allowed to change
+	var regions [][2]int
+	for i, m := range modifications {
+		count := m.count(modified)
+		if count != m.Count {
+			return nil, fmt.Errorf("%w: found %d occurrence(s) of %q, expected %d", ErrUnknownModifications, count, m.Old, m.Count)
+		}
+
+		if confirm != nil && !confirm(m.step(i, len(modifications))) {
+			return nil, ErrAborted
+		}
+
+		for _, offset := range m.offsets(modified) {
+			regions = append(regions, [2]int{offset, offset + m.Length})
+		}
+
+		// Replace all occurrences, making sure to keep the binary the same length
+		modified = m.apply(modified)
+	}
+
+	// Any changes to the length would break the binary
+	if len(modified) != len(original) {
+		return nil, fmt.Errorf("length of modified binary does not match length of original")
+	}
+
+	// Record provenance for support (see marker.go), the marker is not part of any modification
+	modified, markerAt, marked := setMarker(modified, new)
+	if marked {
+		regions = append(regions, [2]int{markerAt, markerAt + markerLength})
+	}
+
+	// Final safety net against bugs in any of the modifications, nothing outside the patched values may ever change
+	if err := verifyChangedRegions(original, modified, regions); err != nil {
+		return nil, err
+	}
+
+	// Make sure the result is a "clean" binary for the new provider, since wrong assumptions about the current state
+	// (e.g. BF2Hub's backend-specific values) could otherwise leave a mix of providers behind
+	if err := verifyPatched(modified, new); err != nil {
+		return nil, err
+	}
+
+	return modified, nil
+}
+
+// verifyChangedRegions checks that every byte differing between original and modified is covered by one of the regions
+func verifyChangedRegions(original, modified []byte, regions [][2]int) error {
+	for i := range original {
+		if original[i] != modified[i] && !inRegions(i, regions) {
+			return fmt.Errorf("modified binary failed validation: unexpected change at offset %#x", i)
+		}
+	}
+
+	return nil
+}
+
+func inRegions(offset int, regions [][2]int) bool {
+	for _, r := range regions {
+		if offset >= r[0] && offset < r[1] {
+			return true
+		}
+	}
+
+	return false
+}
+
+func verifyPatched(b []byte, expected Provider) error {
+	actual, err := DetermineCurrentlyUsedProvider(b)
+	if err != nil {
+		return fmt.Errorf("modified binary failed validation: %w", err)
+	}
+
+	if actual.Name != expected.Name {
+		return fmt.Errorf("modified binary failed validation: detected provider %s, expected %s", actual.DisplayName, expected.DisplayName)
+	}
+
+	return nil
+}
+
+// DetectProvider determines the provider currently used by the binary in the given dir
+func DetectProvider(dir string) (Provider, error) {
+	b, err := os.ReadFile(filepath.Join(dir, BF2ExecutableName))
+	if err != nil {
+		return Provider{}, err
+	}
+
+	return DetermineCurrentlyUsedProvider(b)
+}
+
+func DetermineCurrentlyUsedProvider(b []byte) (Provider, error) {
+	for _, p := range Providers {
+		// Hosts path is a Windows path and might thus be present in any casing
+		ridges := append(p.Fingerprint.Additional, p.Fingerprint.Hostname)
+		if containsAll(b, ridges) && containsFold(b, p.Fingerprint.HostsPath) {
+			// Partially applied PlayBF2 patches may still contain another provider's (or PlayBF2's own) fingerprint
+			if isPartialPlayBF2Patch(b) && (p.Name != PlayBF2.Name || !hasExpectedCounts(b, PlayBF2)) {
+				return Provider{}, ErrPartialPlayBF2Patch
+			}
+			return p, nil
+		}
+	}
+
+	if isPartialPlayBF2Patch(b) {
+		return Provider{}, ErrPartialPlayBF2Patch
+	}
+
+	if packer, ok := detectPacker(b); ok {
+		return Provider{}, fmt.Errorf("%w (detected packer: %s)", ErrPackedBinary, packer)
+	}
+
+	return Provider{}, ErrUnknownModifications
+}
+
+// DumpSlots lists every known provider-specific value found in the binary along with the number of occurrences
+func DumpSlots(b []byte) string {
+	var sb strings.Builder
+	for _, p := range Providers {
+		for _, m := range getModifications(p, GameSpy) {
+			count := m.count(b)
+			if count == 0 {
+				continue
+			}
+			fmt.Fprintf(&sb, "  [%s] %s: found %d, expected %d\n", p.Name, m.Old, count, m.Count)
+		}
+	}
+
+	if sb.Len() == 0 {
+		return "  no known values found\n"
+	}
+
+	return sb.String()
+}
+
+func getModifications(old, new Provider) []modification {
+	// Default modifications, required for patching any provider
+	modifications := []modification{
+		{
+			Old:        old.Fingerprint.HostsPath,
+			New:        new.Fingerprint.HostsPath,
+			Length:     18,
+			Count:      1,
+			IgnoreCase: true,
+		},
+		{
+			Old:    []byte(fmt.Sprintf("gamestats.%s", old.Fingerprint.Hostname)),
+			New:    []byte(fmt.Sprintf("gamestats.%s", new.Fingerprint.Hostname)),
+			Length: 21,
+			Count:  2,
+		},
+		{
+			// Path ("/bf2/getplayerinfo.aspx?pid=") is the same for all providers and kept as is
+			Old:        []byte(fmt.Sprintf("http://stage-net.%s", old.Fingerprint.Hostname)),
+			New:        []byte(fmt.Sprintf("http://stage-net.%s", new.Fingerprint.Hostname)),
+			Length:     56,
+			Count:      1,
+			KeepSuffix: true,
+		},
+		{
+			Old: []byte(fmt.Sprintf("BF2Web.%s", old.Fingerprint.Hostname)),
+			New: []byte(fmt.Sprintf("BF2Web.%s", new.Fingerprint.Hostname)),
+			// Actual length of original is 18. However, "BF2Web.%s" would also match the below modification
+			// and break the url, so add another trailing nil-byte to avoid the partial match
+			Length: 19,
+			Count:  1,
+		},
+		{
+			// Path ("/ASP/") is the same for all providers and kept as is
+			Old:        []byte(fmt.Sprintf("http://BF2Web.%s", old.Fingerprint.Hostname)),
+			New:        []byte(fmt.Sprintf("http://BF2Web.%s", new.Fingerprint.Hostname)),
+			Length:     30,
+			Count:      1,
+			KeepSuffix: true,
+		},
+		{
+			Old:    []byte(fmt.Sprintf("%%s.available.%s", old.Fingerprint.Hostname)),
+			New:    []byte(fmt.Sprintf("%%s.available.%s", new.Fingerprint.Hostname)),
+			Length: 24,
+			Count:  1,
+		},
+		{
+			Old:    []byte(fmt.Sprintf("%%s.master.%s", old.Fingerprint.Hostname)),
+			New:    []byte(fmt.Sprintf("%%s.master.%s", new.Fingerprint.Hostname)),
+			Length: 21,
+			Count:  1,
+		},
+		{
+			Old:    []byte(fmt.Sprintf("gpcm.%s", old.Fingerprint.Hostname)),
+			New:    []byte(fmt.Sprintf("gpcm.%s", new.Fingerprint.Hostname)),
+			Length: 16,
+			Count:  1,
+		},
+		{
+			Old:    []byte(fmt.Sprintf("gpsp.%s", old.Fingerprint.Hostname)),
+			New:    []byte(fmt.Sprintf("gpsp.%s", new.Fingerprint.Hostname)),
+			Length: 16,
+			Count:  1,
+		},
+	}
+
+	// Semi backend-specific modifications (common for some backends)
+	// Special case for PlayBF2: They remove the numeric placeholder/verb ("%d") in addition to changing the hostname
+	if old.Name == PlayBF2.Name {
+		// Remove "%d" when currently patched for PlayBF2
+		modifications = append(modifications, modification{
+			Old:    []byte(fmt.Sprintf("%%s.ms.%s", old.Fingerprint.Hostname)),
+			New:    []byte(fmt.Sprintf("%%s.ms%%d.%s", new.Fingerprint.Hostname)),
+			Length: 19,
+			Count:  1,
+		})
+	} else if new.Name == PlayBF2.Name {
+		// Add "%d" when patching to PlayBF2
+		modifications = append(modifications, modification{
+			Old:    []byte(fmt.Sprintf("%%s.ms%%d.%s", old.Fingerprint.Hostname)),
+			New:    []byte(fmt.Sprintf("%%s.ms.%s", new.Fingerprint.Hostname)),
+			Length: 19,
+			Count:  1,
+		})
+	} else {
+		// Symmetrical change for all other providers
+		modifications = append(modifications, modification{
+			Old:    []byte(fmt.Sprintf("%%s.ms%%d.%s", old.Fingerprint.Hostname)),
+			New:    []byte(fmt.Sprintf("%%s.ms%%d.%s", new.Fingerprint.Hostname)),
+			Length: 19,
+			Count:  1,
+		})
+	}
+
+	// Truly backend-specific modifications (unique to a single backend to be applied/reverted)
+	switch old.Name {
+	case BF2Hub.Name:
+		modifications = append(modifications, modification{
+			Old:    []byte("bf2hbc.dll"),
+			New:    []byte("WS2_32.dll"),
+			Length: 10,
+			Count:  1,
+		},
+		)
+	}
+
+	switch new.Name {
+	case BF2Hub.Name:
+		modifications = append(modifications, modification{
+			Old:    []byte("WS2_32.dll"),
+			New:    []byte("bf2hbc.dll"),
+			Length: 10,
+			Count:  1,
+		},
+		)
+	}
+
+	return modifications
+}
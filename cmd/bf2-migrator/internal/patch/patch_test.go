@@ -0,0 +1,355 @@
+package patch
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestPatchBF2Hub(t *testing.T) {
+	tests := []struct {
+		name string
+		from Provider
+		to   Provider
+	}{
+		{name: "BF2Hub to GameSpy", from: BF2Hub, to: GameSpy},
+		{name: "BF2Hub to OpenSpy", from: BF2Hub, to: OpenSpy},
+		{name: "BF2Hub to PlayBF2", from: BF2Hub, to: PlayBF2},
+		{name: "GameSpy to BF2Hub", from: GameSpy, to: BF2Hub},
+		{name: "OpenSpy to BF2Hub", from: OpenSpy, to: BF2Hub},
+		{name: "PlayBF2 to BF2Hub", from: PlayBF2, to: BF2Hub},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			original := fixtureFor(tt.from)
+			dir := writeFixture(t, original)
+
+			if _, err := PatchBinary(dir, tt.to); err != nil {
+				t.Fatalf("expected no error, got %v", err)
+			}
+
+			if patched := readFixture(t, dir); !bytes.Equal(patched, fixtureFor(tt.to)) {
+				t.Errorf("expected patched binary to equal %s binary", tt.to.Name)
+			}
+
+			backup, err := os.ReadFile(BackupPath(dir, tt.from))
+			if err != nil {
+				t.Fatalf("expected backup, got %v", err)
+			}
+			if !bytes.Equal(backup, original) {
+				t.Errorf("expected backup to equal binary from before patching")
+			}
+		})
+	}
+}
+
+func TestPatchBF2HubRejectsMixedResult(t *testing.T) {
+	// BF2Hub binary which (unexpectedly) also contains OpenSpy's values, so reverting the BF2Hub-specific values alone
+	// results in a binary detected as OpenSpy rather than GameSpy
+	original := append(fixtureFor(BF2Hub), "\\drivers\\etz\\hosts\x00openspy.net\x00"...)
+	dir := writeFixture(t, original)
+
+	if _, err := PatchBinary(dir, GameSpy); err == nil {
+		t.Fatalf("expected error, got nil")
+	}
+
+	if b := readFixture(t, dir); !bytes.Equal(b, original) {
+		t.Errorf("expected binary to be unchanged")
+	}
+	if HasBackup(dir) {
+		t.Errorf("expected no backup to be created")
+	}
+}
+
+func TestVerifyChangedRegions(t *testing.T) {
+	original := fixtureFor(GameSpy)
+	patched, err := ApplyModifications(original, GameSpy, OpenSpy)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	var regions [][2]int
+	for _, m := range getModifications(GameSpy, OpenSpy) {
+		for _, offset := range m.offsets(original) {
+			regions = append(regions, [2]int{offset, offset + m.Length})
+		}
+	}
+
+	// change returns a copy of the patched binary with the byte at the given offset changed
+	change := func(offset int) []byte {
+		b := append([]byte{}, patched...)
+		b[offset] ^= 0xff
+		return b
+	}
+
+	tests := []struct {
+		name     string
+		modified []byte
+		wantErr  bool
+	}{
+		{
+			name:     "only patched values changed",
+			modified: patched,
+		},
+		{
+			name:     "out-of-band change",
+			modified: change(len(patched) - 1),
+			wantErr:  true,
+		},
+		{
+			name:     "change directly after a patched value",
+			modified: change(regions[0][1]),
+			wantErr:  true,
+		},
+		{
+			name:     "change within a patched value",
+			modified: change(regions[0][1] - 1),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := verifyChangedRegions(original, tt.modified, regions)
+			if tt.wantErr && err == nil {
+				t.Errorf("expected error, got nil")
+			} else if !tt.wantErr && err != nil {
+				t.Errorf("expected no error, got %v", err)
+			}
+		})
+	}
+}
+
+func TestPatchRejectsOutOfBandChange(t *testing.T) {
+	// Modification with a length shorter than its values (e.g. due to a typo), so applying it changes bytes beyond the
+	// slot it was found in
+	faulty := modification{Old: []byte("gpcm.gamespy.com"), New: []byte("gpcm.openspy.net"), Length: 10, Count: 1}
+	modificationsFor = func(old, new Provider) []modification {
+		modifications := getModifications(old, new)
+		for i, m := range modifications {
+			if bytes.Equal(m.Old, faulty.Old) {
+				modifications[i] = faulty
+			}
+		}
+		return modifications
+	}
+	t.Cleanup(func() {
+		modificationsFor = getModifications
+	})
+
+	original := fixtureFor(GameSpy)
+	dir := writeFixture(t, original)
+
+	_, err := PatchBinary(dir, OpenSpy)
+	if err == nil || !strings.Contains(err.Error(), "unexpected change") {
+		t.Fatalf("expected error about an unexpected change, got %v", err)
+	}
+
+	if b := readFixture(t, dir); !bytes.Equal(b, original) {
+		t.Errorf("expected binary to be unchanged")
+	}
+	if HasBackup(dir) {
+		t.Errorf("expected no backup to be created")
+	}
+}
+
+func TestPatchMatrix(t *testing.T) {
+	// revert is what the revert button does, regardless of the requested provider
+	revert := func(dir string, _ Provider) (Result, error) {
+		return RevertBinaryInteractive(dir, nil)
+	}
+
+	buttons := []struct {
+		name   string
+		action func(dir string, requested Provider) (Result, error)
+		// target returns the provider the binary is expected to use after the action
+		target func(requested Provider) Provider
+	}{
+		{name: "patch", action: PatchBinary, target: func(requested Provider) Provider { return requested }},
+		{name: "revert", action: revert, target: func(_ Provider) Provider { return GameSpy }},
+	}
+
+	for _, button := range buttons {
+		for _, current := range Providers {
+			for _, requested := range Providers {
+				t.Run(button.name+" "+current.Name+" to "+requested.Name, func(t *testing.T) {
+					dir := writeFixture(t, fixtureFor(current))
+					target := button.target(requested)
+
+					result, err := button.action(dir, requested)
+					if err != nil {
+						t.Fatalf("expected no error, got %v", err)
+					}
+
+					if result.From.Name != current.Name || result.To.Name != target.Name {
+						t.Errorf("expected %s → %s, got %s → %s", current.Name, target.Name, result.From.Name, result.To.Name)
+					}
+
+					wantNoOp := current.Name == target.Name
+					if result.NoOp != wantNoOp {
+						t.Errorf("expected no-op to be %t, got %t", wantNoOp, result.NoOp)
+					}
+					if modified := len(result.ModifiedOffsets) > 0; modified == wantNoOp {
+						t.Errorf("expected modifications only if not a no-op, got %d modified offsets", len(result.ModifiedOffsets))
+					}
+					if backup := HasBackup(dir); backup == wantNoOp {
+						t.Errorf("expected backup only if not a no-op, got backup: %t", backup)
+					}
+
+					if b := readFixture(t, dir); !bytes.Equal(b, fixtureFor(target)) {
+						t.Errorf("expected binary to equal %s binary", target.Name)
+					}
+				})
+			}
+		}
+	}
+}
+
+func TestApplyModifications(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    []byte
+		from    Provider
+		to      Provider
+		want    []byte
+		wantErr error
+	}{
+		{
+			name: "GameSpy to OpenSpy",
+			data: fixtureFor(GameSpy),
+			from: GameSpy,
+			to:   OpenSpy,
+			want: fixtureFor(OpenSpy),
+		},
+		{
+			name: "PlayBF2 to BF2Hub",
+			data: fixtureFor(PlayBF2),
+			from: PlayBF2,
+			to:   BF2Hub,
+			want: fixtureFor(BF2Hub),
+		},
+		{
+			name: "same provider",
+			data: fixtureFor(OpenSpy),
+			from: OpenSpy,
+			to:   OpenSpy,
+			want: fixtureFor(OpenSpy),
+		},
+		{
+			name: "binary does not use from provider",
+			data: fixtureFor(OpenSpy),
+			from: GameSpy,
+			to:   PlayBF2,
+		},
+		{
+			name:    "unknown binary",
+			data:    []byte("not a BF2 binary"),
+			from:    GameSpy,
+			to:      OpenSpy,
+			wantErr: ErrUnknownModifications,
+		},
+		{
+			name:    "partial PlayBF2 patch",
+			data:    bytes.Replace(fixtureFor(GameSpy), []byte("gpcm.gamespy.com"), []byte("gpcm.playbf2.ru"), 1),
+			from:    GameSpy,
+			to:      OpenSpy,
+			wantErr: ErrPartialPlayBF2Patch,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := append([]byte{}, tt.data...)
+
+			got, err := ApplyModifications(data, tt.from, tt.to)
+			if !bytes.Equal(data, tt.data) {
+				t.Errorf("expected input to be unchanged")
+			}
+
+			if tt.want == nil {
+				if err == nil {
+					t.Fatalf("expected error, got nil")
+				}
+				if tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+					t.Errorf("expected %v, got %v", tt.wantErr, err)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("expected no error, got %v", err)
+			}
+			if !bytes.Equal(got, tt.want) {
+				t.Errorf("expected binary to equal %s binary", tt.to.Name)
+			}
+
+			// The result must never share memory with the input, even if nothing was modified
+			if len(got) > 0 && &got[0] == &data[0] {
+				t.Errorf("expected a copy of the input")
+			}
+		})
+	}
+}
+
+func TestModifyBinaryUnexpectedCount(t *testing.T) {
+	// Stock binary containing one of the values once more than expected
+	b := append(fixtureFor(GameSpy), "gpcm.gamespy.com\x00"...)
+
+	if _, err := modifyBinary(b, GameSpy, OpenSpy, nil); !errors.Is(err, ErrUnknownModifications) {
+		t.Errorf("expected %v, got %v", ErrUnknownModifications, err)
+	}
+}
+
+func TestHostsPathIgnoresCase(t *testing.T) {
+	for _, p := range Providers {
+		t.Run(p.Name, func(t *testing.T) {
+			// Windows paths are case-insensitive, so (third-party) patchers may write the hosts path in any casing
+			values := append([]string{}, fixtureValues[p.Name]...)
+			values[0] = strings.ToUpper(values[0])
+			original := buildFixture(values)
+			dir := writeFixture(t, original)
+
+			detected, err := DetermineCurrentlyUsedProvider(original)
+			if err != nil {
+				t.Fatalf("expected no error, got %v", err)
+			}
+			if detected.Name != p.Name {
+				t.Fatalf("expected %s, got %s", p.Name, detected.Name)
+			}
+
+			// Patch stock binaries, revert any other
+			target := GameSpy
+			if p.Name == GameSpy.Name {
+				target = OpenSpy
+			}
+
+			plan, err := PlanPatch(dir, target)
+			if err != nil {
+				t.Fatalf("expected no error, got %v", err)
+			}
+			if !plan.Valid() {
+				t.Errorf("expected all values to be found as often as expected, got:\n%s", plan.Summary())
+			}
+
+			var result Result
+			if target.Name == GameSpy.Name {
+				result, err = RevertBinaryInteractive(dir, nil)
+			} else {
+				result, err = PatchBinary(dir, target)
+			}
+			if err != nil {
+				t.Fatalf("expected no error, got %v", err)
+			}
+			if result.From.Name != p.Name || result.To.Name != target.Name {
+				t.Errorf("expected %s → %s, got %s → %s", p.Name, target.Name, result.From.Name, result.To.Name)
+			}
+
+			// New values are always written as is
+			if b := readFixture(t, dir); !bytes.Equal(b, fixtureFor(target)) {
+				t.Errorf("expected binary to equal %s binary", target.Name)
+			}
+		})
+	}
+}
@@ -0,0 +1,129 @@
+package patch
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+type PlannedModification struct {
+	Old      string
+	New      string
+	Expected int
+	Found    int
+}
+
+// Plan describes what patching a binary would change, without changing anything
+type Plan struct {
+	From          Provider
+	To            Provider
+	Modifications []PlannedModification
+}
+
+// NoOp returns true if the binary already uses the target provider
+func (p Plan) NoOp() bool {
+	return p.From.Name == p.To.Name
+}
+
+// Valid returns true if every value was found in the binary exactly as often as expected
+func (p Plan) Valid() bool {
+	for _, m := range p.Modifications {
+		if m.Found != m.Expected {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Summary returns a human-readable description of the plan, listing expected and found counts for every value
+func (p Plan) Summary() string {
+	if p.NoOp() {
+		return fmt.Sprintf("%s already uses %s, no changes required", BF2ExecutableName, p.To.DisplayName)
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "Patching %s from %s to %s\n\n", BF2ExecutableName, p.From.DisplayName, p.To.DisplayName)
+	discrepancies := 0
+	for _, m := range p.Modifications {
+		marker := ""
+		if m.Found != m.Expected {
+			marker = " (mismatch)"
+			discrepancies++
+		}
+		fmt.Fprintf(&sb, "%s → %s: found %d, expected %d%s\n", m.Old, m.New, m.Found, m.Expected, marker)
+	}
+
+	if discrepancies == 0 {
+		sb.WriteString("\nAll values match expectations")
+	} else {
+		fmt.Fprintf(&sb, "\n%d value(s) do not match expectations", discrepancies)
+	}
+
+	return sb.String()
+}
+
+// PlanPatch determines what patching the binary in the given dir to the given provider would change
+func PlanPatch(dir string, new Provider) (Plan, error) {
+	b, err := os.ReadFile(filepath.Join(dir, BF2ExecutableName))
+	if err != nil {
+		return Plan{}, err
+	}
+
+	old, err := DetermineCurrentlyUsedProvider(b)
+	if err != nil {
+		return Plan{}, err
+	}
+
+	plan := Plan{
+		From: old,
+		To:   new,
+	}
+	if plan.NoOp() {
+		return plan, nil
+	}
+
+	// Count on the progressively modified binary, same as when actually patching
+	modified := b
+	for _, m := range getModifications(old, new) {
+		plan.Modifications = append(plan.Modifications, PlannedModification{
+			Old:      string(m.Old),
+			New:      string(m.New),
+			Expected: m.Count,
+			Found:    m.count(modified),
+		})
+		modified = m.apply(modified)
+	}
+
+	return plan, nil
+}
+
+// ConfirmSteps asks confirm about each modification patching the binary in the given dir to the given provider would
+// apply, returning ErrAborted as soon as one is declined. Nothing is modified, allowing to confirm every change before
+// preparing for patching closes any processes or modifies BF2Hub's settings.
+func ConfirmSteps(dir string, new Provider, confirm ConfirmFunc) error {
+	b, err := os.ReadFile(filepath.Join(dir, BF2ExecutableName))
+	if err != nil {
+		return err
+	}
+
+	old, err := DetermineCurrentlyUsedProvider(b)
+	if err != nil {
+		return err
+	}
+
+	// Nothing to confirm if the binary already uses the provider
+	if old.Name == new.Name {
+		return nil
+	}
+
+	modifications := getModifications(old, new)
+	for i, m := range modifications {
+		if !confirm(m.step(i, len(modifications))) {
+			return ErrAborted
+		}
+	}
+
+	return nil
+}
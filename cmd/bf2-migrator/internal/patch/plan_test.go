@@ -0,0 +1,47 @@
+package patch
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func TestConfirmSteps(t *testing.T) {
+	tests := []struct {
+		name      string
+		current   Provider
+		declineAt int
+		wantSteps int
+		wantErr   error
+	}{
+		{name: "all confirmed", current: GameSpy, wantSteps: len(getModifications(GameSpy, OpenSpy))},
+		{name: "second declined", current: GameSpy, declineAt: 2, wantSteps: 2, wantErr: ErrAborted},
+		{name: "already using provider", current: OpenSpy, wantSteps: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			original := fixtureFor(tt.current)
+			dir := writeFixture(t, original)
+
+			var steps int
+			err := ConfirmSteps(dir, OpenSpy, func(s Step) bool {
+				steps++
+				return s.Index != tt.declineAt
+			})
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("expected %v, got %v", tt.wantErr, err)
+			}
+			if steps != tt.wantSteps {
+				t.Errorf("expected %d step(s) to be confirmed, got %d", tt.wantSteps, steps)
+			}
+
+			if b := readFixture(t, dir); !bytes.Equal(b, original) {
+				t.Errorf("expected binary to be unchanged")
+			}
+			if HasBackup(dir) {
+				t.Errorf("expected no backup to be created")
+			}
+		})
+	}
+}
@@ -0,0 +1,42 @@
+package patch
+
+import (
+	"fmt"
+	"runtime"
+
+	"golang.org/x/sys/windows"
+)
+
+const (
+	bf2RegistryPath      = "SOFTWARE\\Electronic Arts\\EA Games\\Battlefield 2"
+	bf2RegistryPathWow64 = "SOFTWARE\\WOW6432Node\\Electronic Arts\\EA Games\\Battlefield 2"
+)
+
+// bf2RegistryPathFor returns the path of the registry key written by the (32-bit) game installer as seen by a build
+// of this tool for the given architecture. 64-bit builds need to explicitly access the 32-bit view (WOW6432Node),
+// while 32-bit builds must use the plain path: Windows redirects it to WOW6432Node on 64-bit systems, and
+// WOW6432Node does not exist at all on 32-bit systems.
+func bf2RegistryPathFor(arch string) string {
+	if arch == "386" {
+		return bf2RegistryPath
+	}
+
+	return bf2RegistryPathWow64
+}
+
+// PlatformNotes returns notes about known issues when running this build on the current system
+func PlatformNotes() []string {
+	var notes []string
+	if runtime.GOARCH == "386" {
+		var isWow64 bool
+		if err := windows.IsWow64Process(windows.CurrentProcess(), &isWow64); err == nil && isWow64 {
+			notes = append(notes, "Running a 32-bit build on 64-bit Windows, registry access is redirected to WOW6432Node (consider using the 64-bit build)")
+		}
+	}
+
+	if runtime.GOARCH == "arm64" {
+		notes = append(notes, fmt.Sprintf("Running on ARM, %s is only supported via x86 emulation", BF2ExecutableName))
+	}
+
+	return notes
+}
@@ -0,0 +1,32 @@
+package patch
+
+import (
+	"runtime"
+	"testing"
+)
+
+func TestBF2RegistryPathFor(t *testing.T) {
+	tests := []struct {
+		arch string
+		want string
+	}{
+		{arch: "386", want: "SOFTWARE\\Electronic Arts\\EA Games\\Battlefield 2"},
+		{arch: "amd64", want: "SOFTWARE\\WOW6432Node\\Electronic Arts\\EA Games\\Battlefield 2"},
+		{arch: "arm64", want: "SOFTWARE\\WOW6432Node\\Electronic Arts\\EA Games\\Battlefield 2"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.arch, func(t *testing.T) {
+			if got := bf2RegistryPathFor(tt.arch); got != tt.want {
+				t.Errorf("expected %q, got %q", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestInstallDirConfigsUseBuildArch(t *testing.T) {
+	want := bf2RegistryPathFor(runtime.GOARCH)
+	if got := installDirConfigs[0].RegistryPath; got != want {
+		t.Errorf("expected %q for %s build, got %q", want, runtime.GOARCH, got)
+	}
+}
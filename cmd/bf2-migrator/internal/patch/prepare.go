@@ -0,0 +1,291 @@
+package patch
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/mitchellh/go-ps"
+	"golang.org/x/sys/windows/registry"
+)
+
+const (
+	BF2HubRegistryPath = "SOFTWARE\\BF2Hub Systems\\BF2Hub Client"
+
+	savedBF2HubSettingsFileName = "bf2hub-settings.json"
+)
+
+type RegistryRepository interface {
+	OpenKey(k registry.Key, path string, access uint32, cb func(key registry.Key) error) error
+}
+
+// BlockingProcess is a running game/BF2Hub process which needs to be killed before patching
+type BlockingProcess struct {
+	Pid        int
+	Executable string
+	// Path, WindowTitle and StartTime only help to identify the process, each is left empty if it cannot be determined
+	// (e.g. for processes of other users)
+	Path        string
+	WindowTitle string
+	StartTime   time.Time
+}
+
+func (p BlockingProcess) String() string {
+	details := []string{fmt.Sprintf("PID %d", p.Pid)}
+	if !p.StartTime.IsZero() {
+		details = append(details, fmt.Sprintf("started %s", p.StartTime.Format("2006-01-02 15:04:05")))
+	}
+	if p.WindowTitle != "" {
+		details = append(details, fmt.Sprintf("window %q", p.WindowTitle))
+	}
+	if p.Path != "" {
+		details = append(details, p.Path)
+	}
+
+	return fmt.Sprintf("%s (%s)", p.Executable, strings.Join(details, ", "))
+}
+
+// ReviewFunc is called with the processes about to be killed before patching, returning those which should actually be
+// killed. Returning false aborts patching.
+type ReviewFunc func(processes []BlockingProcess) ([]BlockingProcess, bool)
+
+// FindBlockingProcesses returns all running game/BF2Hub processes, which need to be killed before patching
+func FindBlockingProcesses() ([]BlockingProcess, error) {
+	processes, err := ps.Processes()
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve process list: %s", err)
+	}
+
+	blocking := make([]BlockingProcess, 0)
+	for _, process := range processes {
+		executable := process.Executable()
+		if executable == BF2ExecutableName || executable == bf2hubExecutableName {
+			blocking = append(blocking, BlockingProcess{
+				Pid:        process.Pid(),
+				Executable: executable,
+			})
+		}
+	}
+
+	if len(blocking) == 0 {
+		return blocking, nil
+	}
+
+	// Details are only informational, so don't fail if any cannot be determined
+	titles, err := getWindowTitles()
+	if err != nil {
+		titles = map[uint32]string{}
+	}
+	for i := range blocking {
+		blocking[i].Path, _ = processImagePath(blocking[i].Pid)
+		blocking[i].StartTime, _ = processStartTime(blocking[i].Pid)
+		blocking[i].WindowTitle = titles[uint32(blocking[i].Pid)]
+	}
+
+	return blocking, nil
+}
+
+// PrepareForPatch kills any running game/BF2Hub processes and stops BF2Hub from re-patching the binary. If modifying the
+// BF2Hub registry values is skipped, the returned bool indicates whether BF2Hub is installed and may re-patch the binary.
+func PrepareForPatch(r RegistryRepository, skipBF2HubRegistry bool) (bool, error) {
+	return PrepareForPatchReviewed(r, skipBF2HubRegistry, nil)
+}
+
+// PrepareForPatchReviewed is the same as PrepareForPatch, but lets review choose which of the processes to kill (unless
+// review is nil or no processes are running)
+func PrepareForPatchReviewed(r RegistryRepository, skipBF2HubRegistry bool, review ReviewFunc) (bool, error) {
+	processes, err := FindBlockingProcesses()
+	if err != nil {
+		return false, err
+	}
+
+	if review != nil && len(processes) > 0 {
+		var ok bool
+		if processes, ok = review(processes); !ok {
+			return false, ErrAborted
+		}
+	}
+
+	killed := map[int]string{}
+	for _, process := range processes {
+		if err = killProcess(process.Pid); err != nil {
+			return false, fmt.Errorf("failed to kill process %q: %s", process.Executable, err)
+		}
+		killed[process.Pid] = process.Executable
+	}
+
+	err = waitForProcessesToExit(killed)
+	if err != nil {
+		return false, err
+	}
+
+	if skipBF2HubRegistry {
+		// Only check whether BF2Hub is installed without writing anything
+		err = r.OpenKey(registry.CURRENT_USER, BF2HubRegistryPath, registry.QUERY_VALUE, func(key registry.Key) error {
+			return nil
+		})
+		if err != nil {
+			if !errors.Is(err, registry.ErrNotExist) {
+				return false, err
+			}
+			return false, nil
+		}
+		return true, nil
+	}
+
+	// Stop BF2Hub from re-patching the binary
+	err = r.OpenKey(registry.CURRENT_USER, BF2HubRegistryPath, registry.QUERY_VALUE|registry.SET_VALUE, func(key registry.Key) error {
+		// Remember the values BF2Hub was using, so they can be restored when reverting for uninstall (best effort, since
+		// only patching on startup would be re-enabled without them)
+		if s, err2 := readBF2HubSettings(key); err2 == nil && !s.Disabled() {
+			_ = saveBF2HubSettings(s)
+		}
+
+		if err2 := key.SetDWordValue("hrpApplyOnStartup", 0); err2 != nil {
+			return err2
+		}
+
+		if err2 := key.SetDWordValue("hrpInterval", 0); err2 != nil {
+			return err2
+		}
+
+		return nil
+	})
+	if err != nil {
+		// Ignore error if key does not exist, as it would indicate that the BF2Hub Client is not installed and thus
+		// cannot interfere with patching
+		if !errors.Is(err, registry.ErrNotExist) {
+			return false, err
+		}
+	}
+
+	return false, nil
+}
+
+// BF2HubSettings are the BF2Hub registry values modified by PrepareForPatch
+type BF2HubSettings struct {
+	ApplyOnStartup uint64
+	Interval       uint64
+}
+
+// Disabled checks whether BF2Hub is set to never re-patch the binary (as done by PrepareForPatch)
+func (s BF2HubSettings) Disabled() bool {
+	return s.ApplyOnStartup == 0 && s.Interval == 0
+}
+
+// ReadBF2HubSettings reads the BF2Hub registry values modified by PrepareForPatch. The returned bool indicates whether
+// BF2Hub is installed.
+func ReadBF2HubSettings(r RegistryRepository) (BF2HubSettings, bool, error) {
+	var s BF2HubSettings
+	err := r.OpenKey(registry.CURRENT_USER, BF2HubRegistryPath, registry.QUERY_VALUE, func(key registry.Key) error {
+		var err2 error
+		s, err2 = readBF2HubSettings(key)
+		return err2
+	})
+	if err != nil {
+		if !errors.Is(err, registry.ErrNotExist) {
+			return BF2HubSettings{}, false, err
+		}
+		return BF2HubSettings{}, false, nil
+	}
+
+	return s, true, nil
+}
+
+func readBF2HubSettings(key registry.Key) (BF2HubSettings, error) {
+	var s BF2HubSettings
+	var err error
+	if s.ApplyOnStartup, _, err = key.GetIntegerValue("hrpApplyOnStartup"); err != nil && !errors.Is(err, registry.ErrNotExist) {
+		return BF2HubSettings{}, err
+	}
+
+	if s.Interval, _, err = key.GetIntegerValue("hrpInterval"); err != nil && !errors.Is(err, registry.ErrNotExist) {
+		return BF2HubSettings{}, err
+	}
+
+	return s, nil
+}
+
+// savedBF2HubSettingsPath returns the path of the file BF2Hub registry values are saved to before PrepareForPatch
+// modifies them
+func savedBF2HubSettingsPath() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(dir, "bf2-migrator", savedBF2HubSettingsFileName), nil
+}
+
+func saveBF2HubSettings(s BF2HubSettings) error {
+	path, err := savedBF2HubSettingsPath()
+	if err != nil {
+		return err
+	}
+
+	data, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+
+	if err = os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+
+	return os.WriteFile(path, data, 0o644)
+}
+
+// loadSavedBF2HubSettings returns the BF2Hub registry values saved by PrepareForPatch, if any
+func loadSavedBF2HubSettings() (BF2HubSettings, bool) {
+	path, err := savedBF2HubSettingsPath()
+	if err != nil {
+		return BF2HubSettings{}, false
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return BF2HubSettings{}, false
+	}
+
+	var s BF2HubSettings
+	if err = json.Unmarshal(data, &s); err != nil || s.Disabled() {
+		return BF2HubSettings{}, false
+	}
+
+	return s, true
+}
+
+func discardSavedBF2HubSettings() error {
+	path, err := savedBF2HubSettingsPath()
+	if err != nil {
+		return err
+	}
+
+	if err = os.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+
+	return nil
+}
+
+// RestoreBF2HubSettings writes back BF2Hub registry values previously read via ReadBF2HubSettings. Does nothing if
+// BF2Hub is not installed.
+func RestoreBF2HubSettings(r RegistryRepository, s BF2HubSettings) error {
+	err := r.OpenKey(registry.CURRENT_USER, BF2HubRegistryPath, registry.QUERY_VALUE|registry.SET_VALUE, func(key registry.Key) error {
+		if err2 := key.SetDWordValue("hrpApplyOnStartup", uint32(s.ApplyOnStartup)); err2 != nil {
+			return err2
+		}
+
+		return key.SetDWordValue("hrpInterval", uint32(s.Interval))
+	})
+	if err != nil && !errors.Is(err, registry.ErrNotExist) {
+		return err
+	}
+
+	return nil
+}
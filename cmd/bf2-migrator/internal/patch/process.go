@@ -0,0 +1,161 @@
+package patch
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+	"unsafe"
+
+	"github.com/mitchellh/go-ps"
+	"golang.org/x/sys/windows"
+)
+
+var ErrGameNotRunning = fmt.Errorf("%s is not running", BF2ExecutableName)
+
+// DetectRunningProvider infers the provider used by a running game process based on its loaded modules. Since only
+// BF2Hub loads an additional module, the returned bool is false if the provider cannot be determined this way.
+func DetectRunningProvider() (Provider, bool, error) {
+	pid, err := findGameProcess()
+	if err != nil {
+		return Provider{}, false, err
+	}
+
+	modules, err := getProcessModules(pid)
+	if err != nil {
+		return Provider{}, false, fmt.Errorf("failed to list modules of process %d: %w", pid, err)
+	}
+
+	for _, module := range modules {
+		for _, additional := range BF2Hub.Fingerprint.Additional {
+			if strings.EqualFold(module, string(additional)) {
+				return BF2Hub, true, nil
+			}
+		}
+	}
+
+	return Provider{}, false, nil
+}
+
+func findGameProcess() (int, error) {
+	processes, err := ps.Processes()
+	if err != nil {
+		return 0, fmt.Errorf("failed to retrieve process list: %s", err)
+	}
+
+	for _, process := range processes {
+		if process.Executable() == BF2ExecutableName {
+			return process.Pid(), nil
+		}
+	}
+
+	return 0, ErrGameNotRunning
+}
+
+func getProcessModules(pid int) ([]string, error) {
+	// BF2 is a 32-bit game, so make sure to also include 32-bit modules when running as a 64-bit process
+	snapshot, err := windows.CreateToolhelp32Snapshot(windows.TH32CS_SNAPMODULE|windows.TH32CS_SNAPMODULE32, uint32(pid))
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = windows.CloseHandle(snapshot)
+	}()
+
+	var entry windows.ModuleEntry32
+	entry.Size = uint32(unsafe.Sizeof(entry))
+	if err = windows.Module32First(snapshot, &entry); err != nil {
+		return nil, err
+	}
+
+	var modules []string
+	for {
+		modules = append(modules, windows.UTF16ToString(entry.Module[:]))
+		if err = windows.Module32Next(snapshot, &entry); err != nil {
+			if err == windows.ERROR_NO_MORE_FILES {
+				break
+			}
+			return nil, err
+		}
+	}
+
+	return modules, nil
+}
+
+// processImagePath returns the full path of the process' executable
+func processImagePath(pid int) (string, error) {
+	h, err := windows.OpenProcess(windows.PROCESS_QUERY_LIMITED_INFORMATION, false, uint32(pid))
+	if err != nil {
+		return "", err
+	}
+	defer func() {
+		_ = windows.CloseHandle(h)
+	}()
+
+	buf := make([]uint16, windows.MAX_PATH)
+	size := uint32(len(buf))
+	if err = windows.QueryFullProcessImageName(h, 0, &buf[0], &size); err != nil {
+		return "", err
+	}
+
+	return windows.UTF16ToString(buf[:size]), nil
+}
+
+// processStartTime returns the time the process was started at
+func processStartTime(pid int) (time.Time, error) {
+	h, err := windows.OpenProcess(windows.PROCESS_QUERY_LIMITED_INFORMATION, false, uint32(pid))
+	if err != nil {
+		return time.Time{}, err
+	}
+	defer func() {
+		_ = windows.CloseHandle(h)
+	}()
+
+	var creation, exit, kernel, user windows.Filetime
+	if err = windows.GetProcessTimes(h, &creation, &exit, &kernel, &user); err != nil {
+		return time.Time{}, err
+	}
+
+	return time.Unix(0, creation.Nanoseconds()), nil
+}
+
+var (
+	// windowTitles collects the title of the first visible window of each process while enumerating windows
+	windowTitles   map[uint32]string
+	windowTitlesMu sync.Mutex
+	// Callbacks cannot be released and only a limited number can be created, so create a single one up front
+	enumWindowsCallback = windows.NewCallback(func(hwnd windows.HWND, _ uintptr) uintptr {
+		if !windows.IsWindowVisible(hwnd) {
+			return 1
+		}
+
+		var pid uint32
+		if _, err := windows.GetWindowThreadProcessId(hwnd, &pid); err != nil {
+			return 1
+		}
+		if _, ok := windowTitles[pid]; ok {
+			return 1
+		}
+
+		buf := make([]uint16, 256)
+		if n, err := windows.GetWindowText(hwnd, &buf[0], int32(len(buf))); err == nil && n > 0 {
+			windowTitles[pid] = windows.UTF16ToString(buf[:n])
+		}
+
+		// Continue enumerating
+		return 1
+	})
+)
+
+// getWindowTitles returns the title of the first visible window of every process which has one, keyed by PID
+func getWindowTitles() (map[uint32]string, error) {
+	windowTitlesMu.Lock()
+	defer windowTitlesMu.Unlock()
+
+	windowTitles = map[uint32]string{}
+	if err := windows.EnumWindows(enumWindowsCallback, nil); err != nil {
+		return nil, err
+	}
+
+	return windowTitles, nil
+}
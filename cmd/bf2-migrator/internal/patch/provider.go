@@ -0,0 +1,102 @@
+package patch
+
+import (
+	"fmt"
+	"strings"
+)
+
+const (
+	BF2ExecutableName    = "BF2.exe"
+	bf2hubExecutableName = "bf2hub.exe"
+)
+
+type Provider struct {
+	// Name is the provider's identifier (e.g. used to select a provider via the local HTTP API)
+	Name string
+	// DisplayName is the user-facing name to be used in labels and messages
+	DisplayName string
+	Fingerprint fingerprint
+}
+
+type fingerprint struct {
+	Hostname   []byte
+	HostsPath  []byte
+	Additional [][]byte
+}
+
+var BF2Hub = Provider{
+	Name:        "BF2Hub",
+	DisplayName: "BF2Hub",
+	Fingerprint: fingerprint{
+		// BF2Hub does not modify the hostname, so modify based on the GameSpy hostname
+		Hostname:  []byte("gamespy.com"),
+		HostsPath: []byte("\\drivers\\xtc\\hosts"),
+		Additional: [][]byte{
+			[]byte("bf2hbc.dll"),
+		},
+	},
+}
+var PlayBF2 = Provider{
+	Name:        "PlayBF2",
+	DisplayName: "PlayBF2",
+	Fingerprint: fingerprint{
+		Hostname:  []byte("playbf2.ru"),
+		HostsPath: []byte("\\drivers\\etc\\hasts"),
+	},
+}
+var OpenSpy = Provider{
+	Name:        "OpenSpy",
+	DisplayName: "OpenSpy",
+	Fingerprint: fingerprint{
+		Hostname:  []byte("openspy.net"),
+		HostsPath: []byte("\\drivers\\etz\\hosts"),
+	},
+}
+var GameSpy = Provider{
+	Name:        "GameSpy",
+	DisplayName: "GameSpy",
+	Fingerprint: fingerprint{
+		Hostname:  []byte("gamespy.com"),
+		HostsPath: []byte("\\drivers\\etc\\hosts"),
+	},
+}
+
+// Providers contains all known providers
+var Providers = []Provider{BF2Hub, PlayBF2, OpenSpy, GameSpy}
+
+// ProviderByName returns the known provider with the given (case-insensitive) name
+func ProviderByName(name string) (Provider, bool) {
+	for _, p := range Providers {
+		if strings.EqualFold(p.Name, name) {
+			return p, true
+		}
+	}
+
+	return Provider{}, false
+}
+
+// ParseProvider returns the known provider with the given (case-insensitive) name, or an error listing all valid
+// names (and suggesting the closest one, if the given name looks like a typo)
+func ParseProvider(name string) (Provider, error) {
+	if p, ok := ProviderByName(name); ok {
+		return p, nil
+	}
+
+	names := make([]string, 0, len(Providers))
+	suggestion := ""
+	best := 0
+	for _, p := range Providers {
+		names = append(names, strings.ToLower(p.Name))
+		// Only suggest names which are reasonably close (e.g. a typo or two)
+		if d := levenshtein(strings.ToLower(name), strings.ToLower(p.Name)); d <= 2 && (suggestion == "" || d < best) {
+			suggestion = strings.ToLower(p.Name)
+			best = d
+		}
+	}
+
+	if suggestion != "" {
+		return Provider{}, fmt.Errorf("unknown backend '%s' (did you mean '%s'?); valid options: %s", name, suggestion, strings.Join(names, ", "))
+	}
+
+	return Provider{}, fmt.Errorf("unknown backend '%s'; valid options: %s", name, strings.Join(names, ", "))
+}
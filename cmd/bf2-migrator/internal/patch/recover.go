@@ -0,0 +1,164 @@
+package patch
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+var ErrPartialPlayBF2Patch = errors.New("binary contains a partially applied PlayBF2 patch, recover it to GameSpy first")
+
+// isPartialPlayBF2Patch checks whether the binary contains any PlayBF2-specific values. Since a complete PlayBF2 patch
+// matches as well, callers need to check the PlayBF2 values' counts for binaries detected as using PlayBF2.
+func isPartialPlayBF2Patch(b []byte) bool {
+	if bytes.Contains(b, PlayBF2.Fingerprint.Hostname) || containsFold(b, PlayBF2.Fingerprint.HostsPath) {
+		return true
+	}
+
+	// PlayBF2 removes the numeric placeholder from the ms hostname, so a binary where the placeholder is missing
+	// but hostnames were not (or only partially) changed originates from PlayBF2
+	for _, p := range Providers {
+		if bytes.Contains(b, padRight([]byte(fmt.Sprintf("%%s.ms.%s", p.Fingerprint.Hostname)), 0, 19)) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// RecoverToGameSpy replaces any known provider-specific value in the binary with the GameSpy value, regardless of
+// which provider each individual value belongs to. Allows getting back to a clean state from binaries containing
+// mixed modifications (e.g. partially applied PlayBF2 patches), which cannot be patched normally. Like patching, it
+// prepares for patching (see PrepareForPatch) and backs up the binary before writing, returning the backup's path.
+// Nothing is prepared if the binary cannot be recovered.
+func RecoverToGameSpy(r RegistryRepository, dir string, skipBF2HubRegistry bool) (string, error) {
+	if dir == "" {
+		return "", ErrNoInstallDir
+	}
+
+	path := filepath.Join(dir, BF2ExecutableName)
+
+	stats, err := os.Stat(path)
+	if err != nil {
+		return "", err
+	}
+
+	original, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+
+	modified, regions := normalizeToGameSpy(original)
+
+	// Any changes to the length would break the binary
+	if len(modified) != len(original) {
+		return "", fmt.Errorf("length of modified binary does not match length of original")
+	}
+
+	// Prefer the signature of a pristine binary of the same version, which covers every slot's exact stock value
+	if signature, ok := gameSpySignatureFor(original); ok {
+		if !signature.matches(modified) {
+			return "", fmt.Errorf("binary contains unknown modifications which cannot be recovered")
+		}
+	} else if !hasExpectedCounts(modified, GameSpy) {
+		return "", fmt.Errorf("binary contains unknown modifications which cannot be recovered")
+	}
+
+	// Same safety net as for patching, nothing outside the known values may ever change
+	if err = verifyChangedRegions(original, modified, regions); err != nil {
+		return "", err
+	}
+
+	// Only prepare once the binary is known to be recoverable, there is no point in closing the game or changing
+	// BF2Hub's settings otherwise
+	if _, err = PrepareForPatch(r, skipBF2HubRegistry); err != nil {
+		return "", fmt.Errorf("failed to prepare for recovering: %w", err)
+	}
+
+	// Check before writing anything, since running out of space mid-way would leave a corrupted binary behind
+	if err = ensureFreeSpace(dir, uint64(len(original)+len(modified))); err != nil {
+		return "", err
+	}
+
+	// Binary does not use any known provider, so it gets a backup of its own
+	backupPath, err := createBackup(dir, original, stats.Mode(), Provider{})
+	if err != nil {
+		return "", fmt.Errorf("failed to create backup: %w", err)
+	}
+
+	if err = os.WriteFile(path, modified, stats.Mode()); err != nil {
+		return backupPath, err
+	}
+
+	return backupPath, nil
+}
+
+// normalizeToGameSpy returns a copy of the binary with every known provider-specific value replaced by the GameSpy
+// value, along with the byte ranges (start, end) which may have been changed
+func normalizeToGameSpy(b []byte) ([]byte, [][2]int) {
+	normalized := make([]byte, len(b))
+	copy(normalized, b)
+
+	var regions [][2]int
+	for _, p := range Providers {
+		if p.Name == GameSpy.Name {
+			continue
+		}
+
+		// Counts are not validated here, since mixed binaries may only contain some of a provider's values
+		for _, m := range getModifications(p, GameSpy) {
+			for _, offset := range m.offsets(normalized) {
+				regions = append(regions, [2]int{offset, offset + m.Length})
+			}
+			normalized = m.apply(normalized)
+		}
+	}
+
+	// Restore numeric placeholder removed by PlayBF2, even if the hostname was not changed (or changed to another provider's)
+	for _, p := range Providers {
+		o := padRight([]byte(fmt.Sprintf("%%s.ms.%s", p.Fingerprint.Hostname)), 0, 19)
+		n := padRight([]byte(fmt.Sprintf("%%s.ms%%d.%s", GameSpy.Fingerprint.Hostname)), 0, 19)
+		for _, offset := range indexAll(normalized, o) {
+			regions = append(regions, [2]int{offset, offset + len(o)})
+		}
+		normalized = bytes.ReplaceAll(normalized, o, n)
+	}
+
+	// Values were only replaced by their GameSpy counterpart so far, restore anything else in the slots (e.g. paths) to
+	// the stock value as well
+	if signature, ok := gameSpySignatureFor(normalized); ok {
+		for _, slot := range signature {
+			for _, offset := range slot.locator().offsets(normalized) {
+				regions = append(regions, [2]int{offset, offset + slot.Length})
+			}
+		}
+		normalized = signature.restore(normalized)
+	}
+
+	// Stock binaries do not carry a marker
+	normalized, markerAt, marked := setMarker(normalized, GameSpy)
+	if marked {
+		regions = append(regions, [2]int{markerAt, markerAt + markerLength})
+	}
+
+	return normalized, regions
+}
+
+// hasExpectedCounts checks whether all of the provider's values are contained in the binary exactly as often as expected
+func hasExpectedCounts(b []byte, p Provider) bool {
+	// Any other provider works as the target, only the old values are relevant here
+	target := OpenSpy
+	if p.Name == OpenSpy.Name {
+		target = GameSpy
+	}
+
+	for _, m := range getModifications(p, target) {
+		if m.count(b) != m.Count {
+			return false
+		}
+	}
+
+	return true
+}
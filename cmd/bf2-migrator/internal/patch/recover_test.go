@@ -0,0 +1,139 @@
+package patch
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"testing"
+)
+
+func TestDetectPlayBF2(t *testing.T) {
+	p, err := DetermineCurrentlyUsedProvider(fixtureFor(PlayBF2))
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if p.Name != PlayBF2.Name {
+		t.Errorf("expected %s, got %s", PlayBF2.Name, p.Name)
+	}
+}
+
+func TestRevertPlayBF2(t *testing.T) {
+	original := fixtureFor(PlayBF2)
+	dir := writeFixture(t, original)
+
+	result, err := RevertBinaryInteractive(dir, nil)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if result.From.Name != PlayBF2.Name || result.To.Name != GameSpy.Name || result.NoOp {
+		t.Errorf("expected %s → %s, got %s → %s (no-op: %t)", PlayBF2.Name, GameSpy.Name, result.From.Name, result.To.Name, result.NoOp)
+	}
+
+	if reverted := readFixture(t, dir); !bytes.Equal(reverted, fixtureFor(GameSpy)) {
+		t.Errorf("expected reverted binary to equal stock binary")
+	}
+
+	backup, err := os.ReadFile(BackupPath(dir, PlayBF2))
+	if err != nil {
+		t.Fatalf("expected backup, got %v", err)
+	}
+	if !bytes.Equal(backup, original) {
+		t.Errorf("expected backup to equal binary from before reverting")
+	}
+}
+
+func TestRecoverPartialPlayBF2(t *testing.T) {
+	stock := fixtureValues[GameSpy.Name]
+	playBF2 := fixtureValues[PlayBF2.Name]
+	// Indexes as per fixtureSlotLengths
+	const hostsPath, gamestats, ms = 0, 1, 10
+
+	tests := []struct {
+		name    string
+		replace map[int]string
+	}{
+		{
+			name:    "only hosts path changed",
+			replace: map[int]string{hostsPath: playBF2[hostsPath]},
+		},
+		{
+			name:    "only some hostnames changed",
+			replace: map[int]string{gamestats: playBF2[gamestats], gamestats + 1: playBF2[gamestats+1]},
+		},
+		{
+			name:    "ms placeholder removed but hostname unchanged",
+			replace: map[int]string{ms: "%s.ms.gamespy.com"},
+		},
+		{
+			name:    "ms placeholder removed with other provider's hostname",
+			replace: map[int]string{ms: "%s.ms.openspy.net"},
+		},
+		{
+			name: "everything but the ms placeholder changed",
+			replace: func() map[int]string {
+				r := map[int]string{}
+				for i, v := range playBF2 {
+					if i != ms {
+						r[i] = v
+					}
+				}
+				return r
+			}(),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			values := append([]string{}, stock...)
+			for i, v := range tt.replace {
+				values[i] = v
+			}
+			b := buildFixture(values)
+
+			if _, err := DetermineCurrentlyUsedProvider(b); !errors.Is(err, ErrPartialPlayBF2Patch) {
+				t.Fatalf("expected %v, got %v", ErrPartialPlayBF2Patch, err)
+			}
+
+			dir := writeFixture(t, b)
+			backupPath, err := RecoverToGameSpy(registryStub{}, dir, false)
+			if err != nil {
+				t.Fatalf("expected no error, got %v", err)
+			}
+			if recovered := readFixture(t, dir); !bytes.Equal(recovered, fixtureFor(GameSpy)) {
+				t.Errorf("expected recovered binary to equal stock binary")
+			}
+
+			backup, err := os.ReadFile(backupPath)
+			if err != nil {
+				t.Fatalf("expected backup, got %v", err)
+			}
+			if !bytes.Equal(backup, b) {
+				t.Errorf("expected backup to equal binary from before recovering")
+			}
+		})
+	}
+}
+
+func TestRecoverUnknownModifications(t *testing.T) {
+	// Stock binary with the ms hostname changed to something no known provider uses
+	values := append([]string{}, fixtureValues[GameSpy.Name]...)
+	values[10] = "%s.ms%d.example.com"
+	original := buildFixture(values)
+	dir := writeFixture(t, original)
+
+	r := &registrySpy{}
+	if _, err := RecoverToGameSpy(r, dir, false); err == nil {
+		t.Fatalf("expected error, got nil")
+	}
+
+	if r.opened != 0 {
+		t.Errorf("expected nothing to be prepared, got %d registry access(es)", r.opened)
+	}
+
+	if b := readFixture(t, dir); !bytes.Equal(b, original) {
+		t.Errorf("expected binary to be unchanged")
+	}
+	if HasBackup(dir) {
+		t.Errorf("expected no backup to be created")
+	}
+}
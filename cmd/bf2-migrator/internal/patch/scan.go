@@ -0,0 +1,103 @@
+package patch
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// DefaultScanDepth is the default number of directory levels below the root searched by ScanInstalls
+const DefaultScanDepth = 4
+
+// skippedScanDirs are (lower-case) names of directories which never contain a game installation
+var skippedScanDirs = map[string]bool{
+	"$recycle.bin":              true,
+	"system volume information": true,
+	"windows":                   true,
+	"programdata":               true,
+	"appdata":                   true,
+	"node_modules":              true,
+	".git":                      true,
+}
+
+// FoundInstall is a game installation found by ScanInstalls
+type FoundInstall struct {
+	Dir string
+	// Provider is the provider used by the binary, only valid if Err is nil
+	Provider Provider
+	Err      error
+}
+
+// ScanInstalls recursively searches the root directory (up to the given depth) for game binaries, returning the
+// directories containing them along with the provider each binary uses. Directories which cannot be read are skipped.
+func ScanInstalls(root string, maxDepth int) ([]FoundInstall, error) {
+	dirs, err := FindInstallDirs(root, maxDepth)
+	if dirs == nil {
+		return nil, err
+	}
+
+	found := make([]FoundInstall, 0, len(dirs))
+	for _, dir := range dirs {
+		p, err2 := DetectProvider(dir)
+		found = append(found, FoundInstall{
+			Dir:      dir,
+			Provider: p,
+			Err:      err2,
+		})
+	}
+
+	return found, err
+}
+
+// FindInstallDirs recursively searches the root directory (up to the given depth) for game binaries, returning the
+// directories containing them. Unlike ScanInstalls, it does not read any of the binaries.
+func FindInstallDirs(root string, maxDepth int) ([]string, error) {
+	root = filepath.Clean(root)
+	if _, err := os.Stat(root); err != nil {
+		return nil, err
+	}
+
+	dirs := make([]string, 0)
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			// Don't abort the entire scan because of a single inaccessible directory
+			if d != nil && d.IsDir() && path != root && errors.Is(err, fs.ErrPermission) {
+				return filepath.SkipDir
+			}
+			return err
+		}
+
+		if d.IsDir() {
+			if path == root {
+				return nil
+			}
+			if skippedScanDirs[strings.ToLower(d.Name())] || scanDepth(root, path) > maxDepth {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		if strings.EqualFold(d.Name(), BF2ExecutableName) {
+			dirs = append(dirs, filepath.Dir(path))
+		}
+
+		return nil
+	})
+	if err != nil {
+		return dirs, err
+	}
+
+	return dirs, nil
+}
+
+// scanDepth returns the number of directory levels path is located below root
+func scanDepth(root string, path string) int {
+	rel, err := filepath.Rel(root, path)
+	if err != nil {
+		return 0
+	}
+
+	return strings.Count(rel, string(filepath.Separator)) + 1
+}
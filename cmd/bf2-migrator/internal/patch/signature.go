@@ -0,0 +1,86 @@
+package patch
+
+// slotSignature is the stock value of a provider-specific slot in a pristine GameSpy binary
+type slotSignature struct {
+	// Value is the complete stock value, without padding
+	Value []byte
+	// Length is the length of the slot, including any padding nil-bytes
+	Length int
+	// Count is the number of times the slot is contained in the binary
+	Count int
+	// Prefix is the number of leading bytes of Value which identify the slot (e.g. scheme and hostname of a url), with
+	// anything following it (e.g. the path) being part of the stock value but not required to find the slot. Zero if
+	// the entire value is required.
+	Prefix int
+	// IgnoreCase indicates that the slot may be found in any casing (e.g. for case-insensitive Windows paths)
+	IgnoreCase bool
+}
+
+// locator returns a modification finding all instances of the slot
+func (s slotSignature) locator() modification {
+	if s.Prefix == 0 {
+		return modification{Old: s.Value, New: s.Value, Length: s.Length, Count: s.Count, IgnoreCase: s.IgnoreCase}
+	}
+
+	prefix := s.Value[:s.Prefix]
+	return modification{Old: prefix, New: prefix, Length: s.Length, Count: s.Count, KeepSuffix: true}
+}
+
+// binarySignature contains all provider-specific slots of a pristine GameSpy binary
+type binarySignature []slotSignature
+
+// restore overwrites every instance of each slot found in b with the exact stock value, keeping the length of b
+// unchanged. Slots need to contain GameSpy values already in order to be found.
+func (s binarySignature) restore(b []byte) []byte {
+	restored := make([]byte, len(b))
+	copy(restored, b)
+
+	for _, slot := range s {
+		value := padRight(slot.Value, 0, slot.Length)
+		for _, i := range slot.locator().offsets(b) {
+			copy(restored[i:i+slot.Length], value)
+		}
+	}
+
+	return restored
+}
+
+// matches checks whether b contains the exact stock value of every slot as often as a pristine binary does
+func (s binarySignature) matches(b []byte) bool {
+	for _, slot := range s {
+		exact := modification{Old: slot.Value, Length: slot.Length}
+		if exact.count(b) != slot.Count {
+			return false
+		}
+	}
+
+	return true
+}
+
+// gameSpySignatures contains the signatures of pristine GameSpy binaries, keyed by version (see Version.String)
+var gameSpySignatures = map[string]binarySignature{
+	"1.5": {
+		{Value: []byte("\\drivers\\etc\\hosts"), Length: 18, Count: 1, IgnoreCase: true},
+		{Value: []byte("gamestats.gamespy.com"), Length: 21, Count: 2},
+		{Value: []byte("http://stage-net.gamespy.com/bf2/getplayerinfo.aspx?pid="), Length: 56, Count: 1, Prefix: 28},
+		{Value: []byte("BF2Web.gamespy.com"), Length: 19, Count: 1},
+		{Value: []byte("http://BF2Web.gamespy.com/ASP/"), Length: 30, Count: 1, Prefix: 25},
+		{Value: []byte("%s.available.gamespy.com"), Length: 24, Count: 1},
+		{Value: []byte("%s.master.gamespy.com"), Length: 21, Count: 1},
+		{Value: []byte("gpcm.gamespy.com"), Length: 16, Count: 1},
+		{Value: []byte("gpsp.gamespy.com"), Length: 16, Count: 1},
+		{Value: []byte("%s.ms%d.gamespy.com"), Length: 19, Count: 1},
+		{Value: []byte("WS2_32.dll"), Length: 10, Count: 1},
+	},
+}
+
+// gameSpySignatureFor returns the signature of a pristine GameSpy binary of the same version as b
+func gameSpySignatureFor(b []byte) (binarySignature, bool) {
+	v, err := ReadVersion(b)
+	if err != nil {
+		return nil, false
+	}
+
+	s, ok := gameSpySignatures[v.String()]
+	return s, ok
+}
@@ -0,0 +1,83 @@
+package patch
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestGameSpySignature(t *testing.T) {
+	signature, ok := gameSpySignatureFor(fixtureFor(GameSpy))
+	if !ok {
+		t.Fatalf("expected signature for version 1.5")
+	}
+
+	for _, p := range Providers {
+		want := p.Name == GameSpy.Name
+		if got := signature.matches(fixtureFor(p)); got != want {
+			t.Errorf("expected signature to match %s binary: %t, got %t", p.Name, want, got)
+		}
+	}
+}
+
+func TestGameSpySignatureRestore(t *testing.T) {
+	signature, ok := gameSpySignatureFor(fixtureFor(GameSpy))
+	if !ok {
+		t.Fatalf("expected signature for version 1.5")
+	}
+
+	// Url paths are kept as is when patching, so they need to be restored from the signature
+	b := bytes.Replace(fixtureFor(GameSpy), []byte("/ASP/"), []byte("/asp/"), 1)
+	if signature.matches(b) {
+		t.Fatalf("expected signature not to match binary with modified path")
+	}
+
+	if restored := signature.restore(b); !bytes.Equal(restored, fixtureFor(GameSpy)) {
+		t.Errorf("expected restored binary to equal stock binary")
+	}
+}
+
+func TestGameSpySignatureCoversModifications(t *testing.T) {
+	signature := gameSpySignatures["1.5"]
+
+	// sameSlot checks whether the slot is the one the modification finds in a GameSpy binary
+	sameSlot := func(slot slotSignature, m modification) bool {
+		l := slot.locator()
+		return bytes.Equal(l.Old, m.Old) && l.Length == m.Length && l.Count == m.Count &&
+			l.KeepSuffix == m.KeepSuffix && l.IgnoreCase == m.IgnoreCase
+	}
+
+	for _, p := range Providers {
+		if p.Name == GameSpy.Name {
+			continue
+		}
+
+		t.Run(p.Name, func(t *testing.T) {
+			for _, m := range getModifications(GameSpy, p) {
+				found := false
+				for _, slot := range signature {
+					if sameSlot(slot, m) {
+						found = true
+						break
+					}
+				}
+				if !found {
+					t.Errorf("expected signature to contain slot for %q (length %d, count %d)", m.Old, m.Length, m.Count)
+				}
+			}
+		})
+	}
+
+	// Patching to BF2Hub modifies every slot, so every slot of the signature must be modified by it
+	for _, slot := range signature {
+		found := false
+		for _, m := range getModifications(GameSpy, BF2Hub) {
+			if sameSlot(slot, m) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			t.Errorf("expected slot %q to be modified when patching to %s", slot.Value, BF2Hub.Name)
+		}
+	}
+}
@@ -0,0 +1,140 @@
+package patch
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const (
+	// UndoMaxAge is how long after patching a patch can be undone, undo state older than this is ignored
+	UndoMaxAge = 30 * time.Minute
+
+	undoDirName = "bf2-migrator-undo"
+)
+
+var (
+	ErrNoUndoState   = errors.New("no patch to undo")
+	ErrBinaryChanged = fmt.Errorf("%s was modified since it was patched, cannot undo", BF2ExecutableName)
+)
+
+// UndoState captures a binary from before it was patched, so the patch can be undone
+type UndoState struct {
+	Dir string `json:"dir"`
+	// Hash is the hex-encoded SHA-256 hash of the binary right after patching, undoing is only safe while it matches
+	Hash      string    `json:"hash"`
+	Original  []byte    `json:"original"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+func newUndoState(dir string, original, modified []byte) UndoState {
+	sum := sha256.Sum256(modified)
+	return UndoState{
+		Dir:       dir,
+		Hash:      hex.EncodeToString(sum[:]),
+		Original:  original,
+		CreatedAt: time.Now(),
+	}
+}
+
+// undoStatePath returns the path of the temp file the undo state for a binary with the given hash is persisted to
+func undoStatePath(hash string) string {
+	return filepath.Join(os.TempDir(), undoDirName, hash+".json")
+}
+
+// SaveUndoState persists the undo state to a temp file, so the patch can still be undone after a crash or restart
+func SaveUndoState(s UndoState) error {
+	data, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+
+	path := undoStatePath(s.Hash)
+	if err = os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return err
+	}
+
+	return os.WriteFile(path, data, 0o600)
+}
+
+// LoadUndoState loads the persisted undo state for the binary currently in the given dir. Returns ErrNoUndoState if
+// there is none, it belongs to another install or it is older than UndoMaxAge.
+func LoadUndoState(dir string, now time.Time) (UndoState, error) {
+	hash, err := hashFile(filepath.Join(dir, BF2ExecutableName))
+	if err != nil {
+		return UndoState{}, err
+	}
+
+	data, err := os.ReadFile(undoStatePath(hash))
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return UndoState{}, ErrNoUndoState
+		}
+		return UndoState{}, err
+	}
+
+	var s UndoState
+	if err = json.Unmarshal(data, &s); err != nil {
+		return UndoState{}, fmt.Errorf("failed to parse undo state: %w", err)
+	}
+
+	if !strings.EqualFold(filepath.Clean(s.Dir), filepath.Clean(dir)) || now.Sub(s.CreatedAt) > UndoMaxAge {
+		return UndoState{}, ErrNoUndoState
+	}
+
+	return s, nil
+}
+
+// DiscardUndoState deletes the persisted undo state (if any)
+func DiscardUndoState(s UndoState) error {
+	if err := os.Remove(undoStatePath(s.Hash)); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+
+	return nil
+}
+
+// Undo restores the binary captured in the undo state, returning the provider it uses. The binary on disk must still be
+// exactly the one produced by patching, anything else would be overwritten without a backup.
+func Undo(s UndoState) (Provider, error) {
+	path := filepath.Join(s.Dir, BF2ExecutableName)
+
+	stats, err := os.Stat(path)
+	if err != nil {
+		return Provider{}, err
+	}
+
+	hash, err := hashFile(path)
+	if err != nil {
+		return Provider{}, err
+	}
+	if hash != s.Hash {
+		return Provider{}, ErrBinaryChanged
+	}
+
+	p, err := DetermineCurrentlyUsedProvider(s.Original)
+	if err != nil {
+		return Provider{}, fmt.Errorf("captured binary does not use a known provider: %w", err)
+	}
+
+	// Check before writing anything, since running out of space mid-way would leave a corrupted binary behind
+	if err = ensureFreeSpace(s.Dir, uint64(len(s.Original))); err != nil {
+		return Provider{}, err
+	}
+
+	if err = os.WriteFile(path, s.Original, stats.Mode()); err != nil {
+		return Provider{}, err
+	}
+
+	// A leftover state file is harmless, since it no longer matches the hash of the binary
+	_ = DiscardUndoState(s)
+
+	return p, nil
+}
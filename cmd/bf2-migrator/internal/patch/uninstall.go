@@ -0,0 +1,65 @@
+package patch
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// RevertForUninstall restores the binary to use GameSpy and re-enables BF2Hub's patching (if BF2Hub is
+// installed), leaving the installation as it was before any changes were made by this tool. The result's NoOp is true
+// if the binary already used GameSpy.
+func RevertForUninstall(r RegistryRepository, dir string) (Result, error) {
+	// BF2Hub registry values are restored below anyway, so don't touch them here
+	if _, err := PrepareForPatch(r, true); err != nil {
+		return Result{}, fmt.Errorf("failed to prepare for reverting: %w", err)
+	}
+
+	result, err := PatchBinary(dir, GameSpy)
+	if err != nil {
+		return result, fmt.Errorf("failed to revert %s: %w", BF2ExecutableName, err)
+	}
+
+	b, err := os.ReadFile(filepath.Join(dir, BF2ExecutableName))
+	if err != nil {
+		return result, fmt.Errorf("failed to read %s: %w", BF2ExecutableName, err)
+	}
+
+	if p, err2 := DetermineCurrentlyUsedProvider(b); err2 != nil || p.Name != GameSpy.Name {
+		return result, fmt.Errorf("%s could not be fully reverted to a stock state", BF2ExecutableName)
+	}
+
+	if err = EnableBF2HubAutoPatch(r); err != nil {
+		return result, fmt.Errorf("failed to re-enable BF2Hub patching: %w", err)
+	}
+
+	return result, nil
+}
+
+// EnableBF2HubAutoPatch undoes the BF2Hub registry changes made by PrepareForPatch, restoring the values saved before
+// they were modified. Without saved values, only patching on startup can be re-enabled (keeping the current interval).
+// Does nothing if BF2Hub is not installed.
+func EnableBF2HubAutoPatch(r RegistryRepository) error {
+	s, ok := loadSavedBF2HubSettings()
+	if !ok {
+		current, installed, err := ReadBF2HubSettings(r)
+		if err != nil {
+			return err
+		}
+		if !installed {
+			return nil
+		}
+
+		// Re-enabling patching on startup is enough for BF2Hub to re-patch the binary whenever it is launched
+		s = BF2HubSettings{ApplyOnStartup: 1, Interval: current.Interval}
+	}
+
+	if err := RestoreBF2HubSettings(r, s); err != nil {
+		return err
+	}
+
+	// Saved values have been restored, the next call to PrepareForPatch will save the then current values
+	_ = discardSavedBF2HubSettings()
+
+	return nil
+}
@@ -0,0 +1,38 @@
+package patch
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestRevertForUninstall(t *testing.T) {
+	tests := []struct {
+		name     string
+		current  Provider
+		wantNoOp bool
+	}{
+		{name: "patched", current: OpenSpy},
+		{name: "already stock", current: GameSpy, wantNoOp: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Keep any saved BF2Hub settings out of the user's config dir
+			t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+			t.Setenv("AppData", t.TempDir())
+			dir := writeFixture(t, fixtureFor(tt.current))
+
+			result, err := RevertForUninstall(registryStub{}, dir)
+			if err != nil {
+				t.Fatalf("expected no error, got %v", err)
+			}
+			if result.NoOp != tt.wantNoOp {
+				t.Errorf("expected no-op to be %t, got %t", tt.wantNoOp, result.NoOp)
+			}
+
+			if b := readFixture(t, dir); !bytes.Equal(b, fixtureFor(GameSpy)) {
+				t.Errorf("expected binary to equal stock binary")
+			}
+		})
+	}
+}
@@ -0,0 +1,162 @@
+package patch
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"syscall"
+	"time"
+
+	"github.com/mitchellh/go-ps"
+)
+
+func killProcess(pid int) error {
+	proc, err := os.FindProcess(pid)
+	if err != nil {
+		return err
+	}
+	if err = proc.Signal(syscall.SIGKILL); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func waitForProcessesToExit(processes map[int]string) error {
+	iterations := 0
+	for ; len(processes) > 0 && iterations < 5; iterations++ {
+		for pid := range processes {
+			proc, err := ps.FindProcess(pid)
+			if err != nil {
+				return fmt.Errorf("failed to check if killed process is still running: %s", err)
+			}
+
+			// Remove process from map if it exited (was no longer found)
+			if proc == nil {
+				delete(processes, pid)
+			}
+		}
+		time.Sleep(1 * time.Second)
+	}
+
+	// Return error if not all processes exited yet
+	if len(processes) > 0 {
+		return fmt.Errorf("timed out waiting for killed processes to exit")
+	}
+
+	return nil
+}
+
+func padRight(b []byte, c byte, l int) []byte {
+	if len(b) >= l {
+		return b
+	}
+
+	p := make([]byte, len(b), l)
+	copy(p, b)
+	for len(p) < l {
+		p = append(p, c)
+	}
+
+	return p
+}
+
+func containsAll(b []byte, subslices [][]byte) bool {
+	for _, subslice := range subslices {
+		if !bytes.Contains(b, subslice) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func isZero(b []byte) bool {
+	for _, c := range b {
+		if c != 0 {
+			return false
+		}
+	}
+
+	return true
+}
+
+// toLowerASCII lower-cases ASCII letters only, keeping the length of binary data (which is not valid UTF-8) unchanged
+func toLowerASCII(b []byte) []byte {
+	lower := make([]byte, len(b))
+	for i, c := range b {
+		if 'A' <= c && c <= 'Z' {
+			c += 'a' - 'A'
+		}
+		lower[i] = c
+	}
+
+	return lower
+}
+
+// indexAll returns the offsets of all non-overlapping occurrences of sep in b
+func indexAll(b []byte, sep []byte) []int {
+	var offsets []int
+	for start := 0; start <= len(b)-len(sep); {
+		i := bytes.Index(b[start:], sep)
+		if i == -1 {
+			break
+		}
+		offsets = append(offsets, start+i)
+		start += i + len(sep)
+	}
+
+	return offsets
+}
+
+// indexAllFold returns the offsets of all non-overlapping, ASCII case-insensitive occurrences of sep in b
+func indexAllFold(b []byte, sep []byte) []int {
+	lb := toLowerASCII(b)
+	ls := toLowerASCII(sep)
+
+	var offsets []int
+	for start := 0; start <= len(lb)-len(ls); {
+		i := bytes.Index(lb[start:], ls)
+		if i == -1 {
+			break
+		}
+		offsets = append(offsets, start+i)
+		start += i + len(ls)
+	}
+
+	return offsets
+}
+
+func containsFold(b []byte, sub []byte) bool {
+	return bytes.Contains(toLowerASCII(b), toLowerASCII(sub))
+}
+
+// levenshtein returns the edit distance between a and b
+func levenshtein(a, b string) int {
+	prev := make([]int, len(b)+1)
+	curr := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+
+	for i := 1; i <= len(a); i++ {
+		curr[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			// Don't use min builtin here to maintain compatibility with go 1.20 (and thus Windows 7)
+			curr[j] = prev[j] + 1
+			if curr[j-1]+1 < curr[j] {
+				curr[j] = curr[j-1] + 1
+			}
+			if prev[j-1]+cost < curr[j] {
+				curr[j] = prev[j-1] + cost
+			}
+		}
+		prev, curr = curr, prev
+	}
+
+	return prev[len(b)]
+}
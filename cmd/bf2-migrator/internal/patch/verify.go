@@ -0,0 +1,148 @@
+package patch
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const (
+	// DefaultVerifyDelay is the default pause between verifying installations, which avoids reading many binaries
+	// back-to-back (e.g. from the same network share)
+	DefaultVerifyDelay = time.Second
+	// VerifyStateMaxAge is how long an interrupted verification can be resumed, older progress is discarded since the
+	// installations may have been patched in the meantime
+	VerifyStateMaxAge = 24 * time.Hour
+
+	verifyStateFileName = "bf2-migrator-verify.json"
+)
+
+// VerifyResult is the (read-only) verification result for a single installation
+type VerifyResult struct {
+	Dir string `json:"dir"`
+	// Provider is the name of the provider used by the binary, empty if it could not be detected
+	Provider string `json:"provider,omitempty"`
+	Error    string `json:"error,omitempty"`
+}
+
+// VerifyInstall detects the provider used by the binary in the given dir without modifying anything
+func VerifyInstall(dir string) VerifyResult {
+	p, err := DetectProvider(dir)
+	if err != nil {
+		return VerifyResult{Dir: dir, Error: err.Error()}
+	}
+
+	return VerifyResult{Dir: dir, Provider: p.Name}
+}
+
+// UsesProvider checks whether the installation was detected as using the given provider
+func (r VerifyResult) UsesProvider(p Provider) bool {
+	return r.Error == "" && r.Provider == p.Name
+}
+
+// VerifyState is the progress of a verification pass over many installations, persisted so an interrupted pass can
+// be resumed without verifying the same installations again
+type VerifyState struct {
+	StartedAt time.Time      `json:"startedAt"`
+	Results   []VerifyResult `json:"results"`
+}
+
+// Verified returns the result for the given dir, if it was verified already
+func (s *VerifyState) Verified(dir string) (VerifyResult, bool) {
+	for _, r := range s.Results {
+		if containsPath([]string{r.Dir}, dir) {
+			return r, true
+		}
+	}
+
+	return VerifyResult{}, false
+}
+
+// DefaultVerifyStatePath returns the path of the temp file verification progress is persisted to by default
+func DefaultVerifyStatePath() string {
+	return filepath.Join(os.TempDir(), verifyStateFileName)
+}
+
+// LoadVerifyState loads the verification progress persisted to the given path. Returns a new, empty state if there
+// is none or it is older than VerifyStateMaxAge.
+func LoadVerifyState(path string, now time.Time) (*VerifyState, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return &VerifyState{StartedAt: now}, nil
+		}
+		return nil, err
+	}
+
+	var s VerifyState
+	if err = json.Unmarshal(data, &s); err != nil {
+		return nil, fmt.Errorf("failed to parse verification progress: %w", err)
+	}
+
+	if now.Sub(s.StartedAt) > VerifyStateMaxAge {
+		return &VerifyState{StartedAt: now}, nil
+	}
+
+	return &s, nil
+}
+
+// SaveVerifyState persists the verification progress to the given path
+func SaveVerifyState(path string, s *VerifyState) error {
+	data, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+
+	// Write to a temporary file first, so being interrupted mid-write does not lose any progress
+	tmp := path + ".tmp"
+	if err = os.WriteFile(tmp, data, 0o600); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp, path)
+}
+
+// DiscardVerifyState deletes the persisted verification progress (if any)
+func DiscardVerifyState(path string) error {
+	if err := os.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+
+	return nil
+}
+
+// FormatVerifyReport lists which installations use the target provider and which need patching
+func FormatVerifyReport(results []VerifyResult, target Provider) string {
+	var sb strings.Builder
+	var ok, outdated, failed int
+	for _, r := range results {
+		switch {
+		case r.Error != "":
+			failed++
+			fmt.Fprintf(&sb, "%s: failed to detect provider (%s)\n", r.Dir, r.Error)
+		case r.UsesProvider(target):
+			ok++
+			fmt.Fprintf(&sb, "%s: uses %s\n", r.Dir, target.DisplayName)
+		default:
+			outdated++
+			fmt.Fprintf(&sb, "%s: needs patching (uses %s)\n", r.Dir, displayNameOf(r.Provider))
+		}
+	}
+
+	fmt.Fprintf(&sb, "\n%d installation(s) use %s, %d need patching, %d could not be verified\n", ok, target.DisplayName, outdated, failed)
+	return sb.String()
+}
+
+// displayNameOf returns the display name of the provider with the given name, falling back to the name itself
+func displayNameOf(name string) string {
+	if p, ok := ProviderByName(name); ok {
+		return p.DisplayName
+	}
+
+	return name
+}
@@ -0,0 +1,62 @@
+package patch
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+var ErrNoVersionInfo = errors.New("binary does not contain version information")
+
+// fixedFileInfoSignature is the signature (0xFEEF04BD, little endian) of the VS_FIXEDFILEINFO structure contained in
+// the binary's version resource
+var fixedFileInfoSignature = []byte{0xbd, 0x04, 0xef, 0xfe}
+
+// Version is a binary's file version as contained in its version resource
+type Version struct {
+	Major    uint16
+	Minor    uint16
+	Build    uint16
+	Revision uint16
+}
+
+// String returns the version as commonly used to refer to BF2 patch levels, e.g. "1.5"
+func (v Version) String() string {
+	return fmt.Sprintf("%d.%d", v.Major, v.Minor)
+}
+
+// Full returns the complete version, e.g. "1.5.3153.0"
+func (v Version) Full() string {
+	return fmt.Sprintf("%d.%d.%d.%d", v.Major, v.Minor, v.Build, v.Revision)
+}
+
+// DetectVersion determines the version of the binary in the given dir
+func DetectVersion(dir string) (Version, error) {
+	b, err := os.ReadFile(filepath.Join(dir, BF2ExecutableName))
+	if err != nil {
+		return Version{}, err
+	}
+
+	return ReadVersion(b)
+}
+
+// ReadVersion extracts the file version from the binary's version resource
+func ReadVersion(b []byte) (Version, error) {
+	i := bytes.Index(b, fixedFileInfoSignature)
+	// Signature is followed by the structure version, then the most and least significant 32 bits of the file version
+	if i == -1 || i+16 > len(b) {
+		return Version{}, ErrNoVersionInfo
+	}
+
+	ms := binary.LittleEndian.Uint32(b[i+8:])
+	ls := binary.LittleEndian.Uint32(b[i+12:])
+	return Version{
+		Major:    uint16(ms >> 16),
+		Minor:    uint16(ms),
+		Build:    uint16(ls >> 16),
+		Revision: uint16(ls),
+	}, nil
+}
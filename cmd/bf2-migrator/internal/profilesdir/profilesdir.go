@@ -0,0 +1,101 @@
+package profilesdir
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/cetteup/conman/pkg/config"
+	"github.com/cetteup/conman/pkg/game"
+	"github.com/cetteup/conman/pkg/handler"
+)
+
+const (
+	// EnvProfilesDir is the environment variable which, if set, overrides the auto-detected profiles folder
+	EnvProfilesDir = "BF2_PROFILES_DIR"
+
+	globalConFileName  = "Global.con"
+	profileConFileName = "Profile.con"
+)
+
+// Handler is a game.Handler which reads profiles from a fixed folder instead of the auto-detected one
+type Handler struct {
+	game.Handler
+	dir string
+}
+
+// FromEnv returns a handler reading profiles from the folder given via EnvProfilesDir, or h itself if the variable
+// is not set
+func FromEnv(h game.Handler) (game.Handler, error) {
+	dir, ok := os.LookupEnv(EnvProfilesDir)
+	if !ok || dir == "" {
+		return h, nil
+	}
+
+	if err := validate(dir); err != nil {
+		return nil, fmt.Errorf("%s does not point at a valid profiles folder: %w", EnvProfilesDir, err)
+	}
+
+	return &Handler{Handler: h, dir: dir}, nil
+}
+
+func (h *Handler) BuildProfilesFolderPath(_ handler.Game) (string, error) {
+	return h.dir, nil
+}
+
+func (h *Handler) ReadGlobalConfig(_ handler.Game) (*config.Config, error) {
+	return h.ReadConfigFile(filepath.Join(h.dir, globalConFileName))
+}
+
+func (h *Handler) GetProfileKeys(_ handler.Game) ([]string, error) {
+	entries, err := os.ReadDir(h.dir)
+	if err != nil {
+		return nil, err
+	}
+
+	keys := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		// Same as conman, only consider folders containing a Profile.con to be profiles
+		if entry.IsDir() && isFile(filepath.Join(h.dir, entry.Name(), profileConFileName)) {
+			keys = append(keys, entry.Name())
+		}
+	}
+
+	return keys, nil
+}
+
+func (h *Handler) ReadProfileConfig(_ handler.Game, profileKey string) (*config.Config, error) {
+	return h.ReadConfigFile(filepath.Join(h.dir, profileKey, profileConFileName))
+}
+
+// validate checks that dir is a folder containing either a Global.con or at least one profile
+func validate(dir string) error {
+	stats, err := os.Stat(dir)
+	if err != nil {
+		return err
+	}
+	if !stats.IsDir() {
+		return fmt.Errorf("%s is not a folder", dir)
+	}
+
+	if isFile(filepath.Join(dir, globalConFileName)) {
+		return nil
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		if entry.IsDir() && isFile(filepath.Join(dir, entry.Name(), profileConFileName)) {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("%s contains neither a %s nor any profiles", dir, globalConFileName)
+}
+
+func isFile(path string) bool {
+	stats, err := os.Stat(path)
+	return err == nil && !stats.IsDir()
+}
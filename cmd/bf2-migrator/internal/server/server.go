@@ -0,0 +1,261 @@
+package server
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/cetteup/conman/pkg/game"
+	"github.com/rs/zerolog/log"
+
+	"github.com/cetteup/bf2-migrator/cmd/bf2-migrator/internal/migrate"
+	"github.com/cetteup/bf2-migrator/cmd/bf2-migrator/internal/patch"
+)
+
+const (
+	DefaultAddr = "127.0.0.1:8420"
+	// EnvToken is the environment variable the token is read from if no token file is given, the token is never
+	// accepted on the command line since any local user could read it from the process list
+	EnvToken = "BF2_MIGRATOR_TOKEN"
+)
+
+// Server exposes the detect/patch/revert/migrate actions via a JSON API, allowing other tools to remote control the
+// migrator. Only a single action is processed at a time.
+type Server struct {
+	title migrate.Title
+	h     game.Handler
+	c     migrate.Client
+	r     patch.RegistryRepository
+	f     patch.Finder
+	token string
+
+	skipBF2HubRegistry bool
+
+	mu sync.Mutex
+}
+
+func New(title migrate.Title, h game.Handler, c migrate.Client, r patch.RegistryRepository, f patch.Finder, token string, skipBF2HubRegistry bool) *Server {
+	return &Server{
+		title: title,
+		h:     h,
+		c:     c,
+		r:     r,
+		f:     f,
+		token: token,
+
+		skipBF2HubRegistry: skipBF2HubRegistry,
+	}
+}
+
+func (s *Server) ListenAndServe(addr string) error {
+	if s.token == "" {
+		return fmt.Errorf("no token configured")
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/detect", s.handle(http.MethodGet, s.detect))
+	mux.HandleFunc("/patch", s.handle(http.MethodPost, s.patch))
+	mux.HandleFunc("/revert", s.handle(http.MethodPost, s.revert))
+	mux.HandleFunc("/migrate", s.handle(http.MethodPost, s.migrate))
+
+	log.Info().Str("addr", addr).Msg("Listening for requests")
+	return http.ListenAndServe(addr, mux)
+}
+
+type request struct {
+	InstallDir string `json:"installDir"`
+	Provider   string `json:"provider"`
+	ProfileKey string `json:"profileKey"`
+
+	// ctx is cancelled if the client disconnects
+	ctx context.Context
+}
+
+type response struct {
+	InstallDir string `json:"installDir,omitempty"`
+	Provider   string `json:"provider,omitempty"`
+	// PreviousProvider is the provider used before patching, NoOp indicates that no changes were required
+	PreviousProvider string   `json:"previousProvider,omitempty"`
+	NoOp             bool     `json:"noOp,omitempty"`
+	BackupPath       string   `json:"backupPath,omitempty"`
+	Migrated         []string `json:"migrated,omitempty"`
+	Error            string   `json:"error,omitempty"`
+}
+
+type statusError struct {
+	status int
+	err    error
+}
+
+func (e statusError) Error() string {
+	return e.err.Error()
+}
+
+func badRequest(format string, a ...any) error {
+	return statusError{status: http.StatusBadRequest, err: fmt.Errorf(format, a...)}
+}
+
+func (s *Server) handle(method string, action func(req request) (response, error)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !s.authorized(r) {
+			writeJSON(w, http.StatusUnauthorized, response{Error: "unauthorized"})
+			return
+		}
+
+		if r.Method != method {
+			writeJSON(w, http.StatusMethodNotAllowed, response{Error: "method not allowed"})
+			return
+		}
+
+		req := request{
+			InstallDir: r.URL.Query().Get("installDir"),
+			ctx:        r.Context(),
+		}
+		if r.Method == http.MethodPost && r.ContentLength != 0 {
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				writeJSON(w, http.StatusBadRequest, response{Error: fmt.Sprintf("invalid request body: %s", err)})
+				return
+			}
+		}
+
+		s.mu.Lock()
+		res, err := action(req)
+		s.mu.Unlock()
+		if err != nil {
+			status := http.StatusInternalServerError
+			if e, ok := err.(statusError); ok {
+				status = e.status
+			}
+			res.Error = err.Error()
+			writeJSON(w, status, res)
+			return
+		}
+
+		writeJSON(w, http.StatusOK, res)
+	}
+}
+
+func (s *Server) authorized(r *http.Request) bool {
+	expected := []byte("Bearer " + s.token)
+	return subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), expected) == 1
+}
+
+func (s *Server) detect(req request) (response, error) {
+	dir, err := s.resolveInstallDir(req)
+	if err != nil {
+		return response{}, err
+	}
+
+	b, err := os.ReadFile(filepath.Join(dir, patch.BF2ExecutableName))
+	if err != nil {
+		return response{InstallDir: dir}, err
+	}
+
+	p, err := patch.DetermineCurrentlyUsedProvider(b)
+	if err != nil {
+		return response{InstallDir: dir}, err
+	}
+
+	return response{InstallDir: dir, Provider: p.Name}, nil
+}
+
+func (s *Server) patch(req request) (response, error) {
+	p, err := patch.ParseProvider(req.Provider)
+	if err != nil {
+		return response{}, badRequest("%s", err.Error())
+	}
+
+	return s.patchTo(req, p)
+}
+
+func (s *Server) revert(req request) (response, error) {
+	return s.patchTo(req, patch.GameSpy)
+}
+
+func (s *Server) patchTo(req request, p patch.Provider) (response, error) {
+	dir, err := s.resolveInstallDir(req)
+	if err != nil {
+		return response{}, err
+	}
+
+	if _, err = patch.PrepareForPatch(s.r, s.skipBF2HubRegistry); err != nil {
+		return response{InstallDir: dir}, err
+	}
+
+	result, err := patch.PatchBinary(dir, p)
+	if err != nil {
+		return response{InstallDir: dir}, err
+	}
+
+	return response{
+		InstallDir:       dir,
+		Provider:         result.To.Name,
+		PreviousProvider: result.From.Name,
+		NoOp:             result.NoOp,
+		BackupPath:       result.BackupPath,
+	}, nil
+}
+
+func (s *Server) migrate(req request) (response, error) {
+	profiles, _, err := s.title.GetProfiles(s.h)
+	if err != nil {
+		return response{}, err
+	}
+
+	// Migrate all eligible profiles unless a specific profile was requested
+	var res response
+	for _, profile := range profiles {
+		if req.ProfileKey != "" && profile.Key != req.ProfileKey {
+			continue
+		}
+		if req.ProfileKey == "" && profile.Type != game.ProfileTypeMultiplayer {
+			continue
+		}
+
+		if len(res.Migrated) > 0 {
+			if err = migrate.Pause(req.ctx, migrate.DefaultBatchDelay); err != nil {
+				return res, fmt.Errorf("cancelled before migrating %q: %w", profile.Name, err)
+			}
+		}
+
+		if err = s.title.MigrateProfile(s.h, s.c, profile); err != nil {
+			err = fmt.Errorf("failed to migrate %q: %w", profile.Name, err)
+			if errors.Is(err, migrate.ErrAccountHasOtherNicks) {
+				// Needs to be confirmed by the account's owner, retrying will not help
+				return res, statusError{status: http.StatusConflict, err: err}
+			}
+			return res, err
+		}
+		res.Migrated = append(res.Migrated, profile.Name)
+	}
+
+	if req.ProfileKey != "" && len(res.Migrated) == 0 {
+		return res, badRequest("unknown profile %q", req.ProfileKey)
+	}
+
+	return res, nil
+}
+
+func (s *Server) resolveInstallDir(req request) (string, error) {
+	if req.InstallDir != "" {
+		return req.InstallDir, nil
+	}
+
+	return patch.DetectInstallPath(s.f)
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Error().
+			Err(err).
+			Msg("Failed to write response")
+	}
+}
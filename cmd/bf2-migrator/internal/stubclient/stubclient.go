@@ -0,0 +1,80 @@
+package stubclient
+
+import (
+	"context"
+	"sync"
+
+	"github.com/rs/zerolog/log"
+
+	api "github.com/cetteup/bf2-migrator/pkg/openspy"
+)
+
+// Client is a stand-in for the OpenSpy API client which only logs the calls it would make and always succeeds,
+// allowing to rehearse migrations without touching any OpenSpy accounts
+type Client struct {
+	mu       sync.Mutex
+	email    string
+	profiles []api.ProfileDTO
+}
+
+func New() *Client {
+	return &Client{}
+}
+
+func (c *Client) CreateAccount(email, password string, partnerCode int) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	log.Info().
+		Str("email", email).
+		Int("partnerCode", partnerCode).
+		Msg("[stub] Would create/log in to OpenSpy account")
+
+	// Profiles belong to an account, so "switching" accounts starts with an empty list
+	if email != c.email {
+		c.email = email
+		c.profiles = nil
+	}
+
+	return nil
+}
+
+func (c *Client) CreateProfile(nick string, namespaceID int) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	log.Info().
+		Str("email", c.email).
+		Str("nick", nick).
+		Int("namespaceID", namespaceID).
+		Msg("[stub] Would create OpenSpy profile")
+
+	c.profiles = append(c.profiles, api.ProfileDTO{
+		ID:          len(c.profiles) + 1,
+		Nick:        nick,
+		UniqueNick:  nick,
+		NamespaceID: namespaceID,
+	})
+
+	return nil
+}
+
+func (c *Client) GetProfiles() ([]api.ProfileDTO, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	log.Info().
+		Str("email", c.email).
+		Msg("[stub] Would get OpenSpy account profiles")
+
+	profiles := make([]api.ProfileDTO, len(c.profiles))
+	copy(profiles, c.profiles)
+
+	return profiles, nil
+}
+
+// OnRetry is a no-op, since the stub never fails (and thus never retries)
+func (c *Client) OnRetry(_ api.RetryFunc) {}
+
+// SetContext is a no-op, since the stub does not send any requests which could be aborted
+func (c *Client) SetContext(_ context.Context) {}
@@ -3,35 +3,262 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"net/url"
 	"os"
+	"strings"
+	"syscall"
+	"time"
 
 	filerepo "github.com/cetteup/filerepo/pkg"
 	"github.com/cetteup/joinme.click-launcher/pkg/registry_repository"
 	"github.com/cetteup/joinme.click-launcher/pkg/software_finder"
+	"github.com/lxn/walk"
+	"github.com/lxn/win"
 	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
 
 	"github.com/cetteup/conman/pkg/handler"
 
+	"github.com/cetteup/bf2-migrator/cmd/bf2-migrator/internal/config"
+	"github.com/cetteup/bf2-migrator/cmd/bf2-migrator/internal/eventlog"
 	"github.com/cetteup/bf2-migrator/cmd/bf2-migrator/internal/gui"
+	"github.com/cetteup/bf2-migrator/cmd/bf2-migrator/internal/instance"
+	"github.com/cetteup/bf2-migrator/cmd/bf2-migrator/internal/migrate"
+	"github.com/cetteup/bf2-migrator/cmd/bf2-migrator/internal/patch"
+	"github.com/cetteup/bf2-migrator/cmd/bf2-migrator/internal/profilesdir"
+	"github.com/cetteup/bf2-migrator/cmd/bf2-migrator/internal/server"
+	"github.com/cetteup/bf2-migrator/cmd/bf2-migrator/internal/stubclient"
 	"github.com/cetteup/bf2-migrator/pkg/openspy"
 )
 
+type client interface {
+	migrate.Client
+	OnRetry(fn openspy.RetryFunc)
+	SetContext(ctx context.Context)
+}
+
 func init() {
 	log.Logger = log.Output(zerolog.ConsoleWriter{Out: os.Stdout})
 }
 
 func main() {
+	skipBF2HubRegistry := flag.Bool("skip-bf2hub-registry", false, "do not modify BF2Hub registry values before patching")
+	revertForUninstall := flag.Bool("revert-for-uninstall", false, "revert the game installation to stock and exit (run before uninstalling the game)")
+	patchTo := flag.String("patch", "", "patch the game to use the given backend (openspy, gamespy, bf2hub, playbf2) and exit")
+	revertAll := flag.Bool("revert-all", false, "revert all detected game installations to stock, re-enable BF2Hub patching and exit")
+	verify := flag.String("verify", "", "report whether all detected game installations use the given backend without changing anything and exit (resumes an interrupted run)")
+	verifyState := flag.String("verify-state", "", "path to persist verification progress to (with -verify, a file in the temp folder is used if not set)")
+	verifyDelay := flag.Duration("verify-delay", patch.DefaultVerifyDelay, "pause between verifying game installations (with -verify), 0 to disable")
+	autoMigrateAll := flag.Bool("auto-migrate-all", false, "migrate all eligible profiles to OpenSpy without showing the GUI and exit")
+	eligibilityReport := flag.String("eligibility-report", "", "write a CSV report on whether each profile can be migrated to the given path and exit (does not contact OpenSpy)")
+	patchOpenSpy := flag.Bool("patch-openspy", false, "also patch the game to use OpenSpy (with -auto-migrate-all)")
+	interactive := flag.Bool("interactive", false, "confirm each change before it is applied, nothing is written unless all changes are confirmed (with -patch)")
+	dryRun := flag.Bool("dry-run", false, "only report what would be done without making any changes (with -patch, -auto-migrate-all or -revert-all)")
+	reportPath := flag.String("report", "", "path to write a report of the results to (with -auto-migrate-all, -revert-all or -verify)")
+	yes := flag.Bool("yes", false, "do not prompt for confirmation")
+	serve := flag.Bool("serve", false, "run a local HTTP server exposing the detect/patch/revert/migrate actions instead of showing the GUI")
+	listenAddr := flag.String("listen", server.DefaultAddr, "address to listen on (with -serve)")
+	tokenFile := flag.String("token-file", "", "path to a file containing the token required to authenticate requests (with -serve, "+server.EnvToken+" is used if not set)")
+	proxy := flag.String("proxy", "", "URL of the HTTP proxy to use for requests to OpenSpy (HTTP_PROXY/HTTPS_PROXY are used if not set)")
+	caCert := flag.String("ca-cert", "", "path to a PEM file containing additional CA certificates to trust for requests to OpenSpy")
+	stubClient := flag.Bool("stub-client", false, "do not send any requests to OpenSpy, only log the requests that would be sent")
+	delay := flag.Duration("delay", migrate.DefaultBatchDelay, "pause between migrating profiles (with -auto-migrate-all), 0 to disable")
+	eventLog := flag.Bool("event-log", false, "also write log events to the Windows Event Log")
+	scanRoot := flag.String("scan", "", "search the given folder for game installations instead of detecting them (with -revert-all or -verify)")
+	configPath := flag.String("config", "", "path to the config file (the one in the user's config folder is used if not set)")
+	checkConfig := flag.Bool("check-config", false, "validate the config file, print any problems and exit")
+	installDir := flag.String("install-dir", "", "path to the game installation folder (detected automatically if not set)")
+	title := flag.String("title", migrate.BF2.Name, "title whose profiles to migrate (currently only bf2)")
+	flag.Parse()
+
+	if *eventLog {
+		w, err := eventlog.New(eventlog.Source)
+		if err != nil {
+			log.Error().Err(err).Msg("Failed to open Windows Event Log, continuing without it")
+		} else {
+			defer w.Close()
+			log.Logger = log.Output(zerolog.MultiLevelWriter(zerolog.ConsoleWriter{Out: os.Stdout}, w))
+		}
+	}
+
+	for _, note := range patch.PlatformNotes() {
+		log.Warn().Msg(note)
+	}
+
+	if *checkConfig {
+		os.Exit(runCheckConfig(*configPath))
+	}
+
+	// Modes which run without showing the GUI, their exit code is all scripts have to go by
+	headless := *revertForUninstall || *patchTo != "" || *revertAll || *verify != "" || *eligibilityReport != "" ||
+		*autoMigrateAll || *serve
+
+	// Running multiple instances at once could result in concurrent writes to the binary
+	release, err := instance.Lock("Local\\bf2-migrator")
+	if err != nil {
+		if errors.Is(err, instance.ErrAlreadyRunning) {
+			if headless {
+				// Focusing the running instance would not do what was asked, so fail instead of silently exiting
+				log.Error().Msg("Another instance of bf2-migrator is already running, close it and try again")
+				os.Exit(exitCodeFailure)
+			}
+			focusRunningInstance()
+			os.Exit(0)
+		}
+		log.Fatal().Err(err).Msg("Failed to acquire single instance lock")
+	}
+	defer release()
+
+	var cfg *config.Config
+	// configNotice explains to the user why their saved settings are not in effect, if they are not
+	var configNotice string
+	if *configPath != "" {
+		cfg, err = config.LoadFrom(*configPath)
+	} else {
+		cfg, err = config.Load()
+	}
+	if err != nil {
+		// Keep the defaults bound to the config file, else changing any setting would fail to save
+		path := *configPath
+		if path == "" {
+			path, _ = config.Path()
+		}
+		cfg = config.New(path)
+
+		if path != "" {
+			log.Error().Err(err).Str("path", path).Msg("Failed to load config, using defaults")
+			configNotice = fmt.Sprintf("Failed to load config from %s, using the default settings instead.\n\n"+
+				"Changing any setting will replace the config file.\n\nError: %s", path, err)
+		} else {
+			log.Error().Err(err).Msg("Failed to load config, using defaults")
+			configNotice = fmt.Sprintf("Failed to load config, using the default settings instead.\n\n"+
+				"Changed settings will not be saved.\n\nError: %s", err)
+		}
+	}
+
+	gameTitle, err := migrate.FindTitle(*title)
+	if err != nil {
+		log.Fatal().Err(err).Msg("Failed to select title")
+	}
+
 	fileRepository := filerepo.New()
 	registryRepository := registry_repository.New()
-	h := handler.New(fileRepository)
+	h, err := profilesdir.FromEnv(handler.New(fileRepository))
+	if err != nil {
+		log.Fatal().Err(err).Msg("Failed to use profiles folder override")
+	}
 
-	c := openspy.New(openspy.BaseURL, 10)
+	var c client
+	if *stubClient {
+		log.Warn().Msg("Using stub client, no changes will be made to any OpenSpy account")
+		c = stubclient.New()
+	} else {
+		oc := openspy.New(openspy.BaseURL, 10)
+		oc.SetRetryPolicy(3, 2*time.Second)
+		if *proxy != "" {
+			proxyURL, err2 := url.Parse(*proxy)
+			if err2 != nil || proxyURL.Host == "" {
+				log.Fatal().Err(err2).Str("proxy", *proxy).Msg("Invalid proxy URL, expected e.g. http://proxy.example.com:8080")
+			}
+			oc.SetProxy(proxyURL)
+		}
+		if *caCert != "" {
+			pemCerts, err2 := os.ReadFile(*caCert)
+			if err2 != nil {
+				log.Fatal().Err(err2).Str("path", *caCert).Msg("Failed to read CA certificate file")
+			}
+			if err2 = oc.AddRootCAs(pemCerts); err2 != nil {
+				log.Fatal().Err(err2).Str("path", *caCert).Msg("Failed to add CA certificates")
+			}
+		}
+		c = oc
+	}
 	f := software_finder.New(registryRepository, fileRepository)
-	mw, err := gui.CreateMainWindow(h, c, f, registryRepository)
+
+	cliOpts := cliOptions{
+		Title:              gameTitle,
+		InstallDir:         *installDir,
+		ScanRoot:           *scanRoot,
+		ReportPath:         *reportPath,
+		PatchOpenSpy:       *patchOpenSpy,
+		DryRun:             *dryRun,
+		Interactive:        *interactive,
+		SkipBF2HubRegistry: cfg.SkipBF2HubRegistry || *skipBF2HubRegistry,
+		Yes:                *yes,
+		Delay:              *delay,
+		VerifyStatePath:    *verifyState,
+		VerifyDelay:        *verifyDelay,
+	}
+	if *revertForUninstall {
+		os.Exit(runRevertForUninstall(registryRepository, f, cliOpts))
+	}
+	if *patchTo != "" {
+		os.Exit(runPatch(registryRepository, f, cliOpts, *patchTo))
+	}
+	if *revertAll {
+		os.Exit(runRevertAll(registryRepository, f, cliOpts))
+	}
+	if *verify != "" {
+		os.Exit(runVerify(f, cliOpts, *verify))
+	}
+	if *eligibilityReport != "" {
+		os.Exit(runEligibilityReport(h, cliOpts, *eligibilityReport))
+	}
+	if *autoMigrateAll {
+		os.Exit(runAutoMigrateAll(h, c, registryRepository, f, cliOpts))
+	}
+	if *serve {
+		token := os.Getenv(server.EnvToken)
+		if *tokenFile != "" {
+			b, err2 := os.ReadFile(*tokenFile)
+			if err2 != nil {
+				log.Fatal().Err(err2).Str("path", *tokenFile).Msg("Failed to read token file")
+			}
+			token = strings.TrimSpace(string(b))
+		}
+
+		s := server.New(gameTitle, h, c, registryRepository, f, token, cliOpts.SkipBF2HubRegistry)
+		if err = s.ListenAndServe(*listenAddr); err != nil {
+			log.Fatal().Err(err).Msg("Failed to run server")
+		}
+		return
+	}
+
+	opts := gui.Options{
+		Title:              gameTitle,
+		SkipBF2HubRegistry: cliOpts.SkipBF2HubRegistry,
+	}
+	mw, err := gui.CreateMainWindow(cfg, opts, h, c, f, registryRepository)
 	if err != nil {
 		log.Fatal().Err(err).Msg("Failed to create main window")
 	}
 
+	if configNotice != "" {
+		// Show the notice once the window is up, so it is not mistaken for the app failing to start
+		mw.Synchronize(func() {
+			walk.MsgBox(mw, "Warning", configNotice, walk.MsgBoxIconWarning)
+		})
+	}
+
 	mw.Run()
 }
+
+func focusRunningInstance() {
+	title, err := syscall.UTF16PtrFromString(gui.WindowTitle)
+	if err != nil {
+		return
+	}
+
+	hwnd := win.FindWindow(nil, title)
+	if hwnd == 0 {
+		walk.MsgBox(nil, "Error", "BF2 migrator is already running", walk.MsgBoxIconError)
+		return
+	}
+
+	win.ShowWindow(hwnd, win.SW_RESTORE)
+	win.SetForegroundWindow(hwnd)
+}
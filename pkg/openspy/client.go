@@ -2,17 +2,26 @@ package openspy
 
 import (
 	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"net"
 	"net/http"
 	"net/url"
 	"strconv"
+	"strings"
 	"time"
 )
 
 const (
 	BaseURL = "http://account.openspy.net/api/"
+
+	// maxProfilePages limits how many pages of profiles are requested, guarding against links looping back
+	maxProfilePages = 50
 )
 
 type RequestError struct {
@@ -31,6 +40,27 @@ func (e RequestError) Error() string {
 	return fmt.Sprintf("request to %s failed with status code %d", e.RequestURL.Redacted(), e.StatusCode)
 }
 
+// TLSError indicates that a secure connection could not be established, e.g. due to a proxy intercepting TLS traffic
+type TLSError struct {
+	RequestURL *url.URL
+	Err        error
+}
+
+func newTLSError(requestURL *url.URL, err error) *TLSError {
+	return &TLSError{
+		RequestURL: requestURL,
+		Err:        err,
+	}
+}
+
+func (e TLSError) Error() string {
+	return fmt.Sprintf("failed to establish secure connection to %s, a proxy or custom root CA certificate may need to be configured: %s", e.RequestURL.Redacted(), e.Err)
+}
+
+func (e TLSError) Unwrap() error {
+	return e.Err
+}
+
 type APIError struct {
 	Code    string
 	Message string
@@ -47,10 +77,18 @@ func (e APIError) Error() string {
 	return fmt.Sprintf("%s (%s)", e.Message, e.Code)
 }
 
+// RetryFunc is called before a failed request is retried, with attempt being the number of the upcoming attempt
+type RetryFunc func(attempt, maxAttempts int, wait time.Duration)
+
 type Client struct {
 	client  http.Client
 	baseURL string
 
+	maxAttempts int
+	retryDelay  time.Duration
+	onRetry     RetryFunc
+
+	ctx       context.Context
 	authToken string
 }
 
@@ -59,8 +97,66 @@ func New(baseURL string, timeout int) *Client {
 		client: http.Client{
 			Timeout: time.Duration(timeout) * time.Second,
 		},
-		baseURL: baseURL,
+		baseURL:     baseURL,
+		maxAttempts: 1,
+		ctx:         context.Background(),
+	}
+}
+
+// SetRetryPolicy configures the client to retry requests which failed due to network errors or server-side issues,
+// doubling the delay between attempts each time
+func (c *Client) SetRetryPolicy(maxAttempts int, delay time.Duration) {
+	if maxAttempts < 1 {
+		maxAttempts = 1
 	}
+	c.maxAttempts = maxAttempts
+	c.retryDelay = delay
+}
+
+// SetProxy routes all requests through the given proxy, overriding any proxy configured via the HTTP_PROXY/HTTPS_PROXY
+// environment variables (which are honored by default)
+func (c *Client) SetProxy(proxyURL *url.URL) {
+	c.transport().Proxy = http.ProxyURL(proxyURL)
+}
+
+// AddRootCAs trusts the given PEM-encoded CA certificates in addition to the system's root CAs
+func (c *Client) AddRootCAs(pemCerts []byte) error {
+	pool, err := x509.SystemCertPool()
+	if err != nil {
+		pool = x509.NewCertPool()
+	}
+
+	if !pool.AppendCertsFromPEM(pemCerts) {
+		return fmt.Errorf("no valid PEM-encoded certificates found")
+	}
+
+	t := c.transport()
+	if t.TLSClientConfig == nil {
+		t.TLSClientConfig = &tls.Config{}
+	}
+	t.TLSClientConfig.RootCAs = pool
+
+	return nil
+}
+
+// transport returns the client's own transport, creating it from the default transport on first use
+func (c *Client) transport() *http.Transport {
+	if c.client.Transport == nil {
+		c.client.Transport = http.DefaultTransport.(*http.Transport).Clone()
+	}
+
+	return c.client.Transport.(*http.Transport)
+}
+
+// OnRetry registers a func to be called whenever a request is retried
+func (c *Client) OnRetry(fn RetryFunc) {
+	c.onRetry = fn
+}
+
+// SetContext sets the context used for all subsequent requests, cancelling it aborts any in-flight request (including
+// waiting for retries)
+func (c *Client) SetContext(ctx context.Context) {
+	c.ctx = ctx
 }
 
 func (c *Client) CreateAccount(email, password string, partnerCode int) error {
@@ -85,7 +181,8 @@ func (c *Client) CreateAccount(email, password string, partnerCode int) error {
 		return err
 	}
 
-	body, err := c.do(req)
+	// Registering is not idempotent, so never send it twice
+	body, _, err := c.do(req, false)
 	if err != nil {
 		return err
 	}
@@ -131,7 +228,8 @@ func (c *Client) CreateProfile(nick string, namespaceID int) error {
 		return err
 	}
 
-	body, err := c.do(req)
+	// Creating a profile is not idempotent, so never send it twice
+	body, _, err := c.do(req, false)
 	if err != nil {
 		return err
 	}
@@ -153,32 +251,95 @@ func (c *Client) GetProfiles() ([]ProfileDTO, error) {
 
 	u = u.JoinPath("profile")
 
-	req, err := c.createRequest(http.MethodGet, u.String(), nil)
-	if err != nil {
-		return nil, err
+	// Follow pagination (if any), since checking only the first page for existing profiles could cause duplicates
+	var profiles []ProfileDTO
+	for page := 1; u != nil; page++ {
+		if page > maxProfilePages {
+			return nil, fmt.Errorf("profile list exceeds %d pages", maxProfilePages)
+		}
+
+		req, err2 := c.createRequest(http.MethodGet, u.String(), nil)
+		if err2 != nil {
+			return nil, err2
+		}
+
+		err2 = c.authenticateRequest(req)
+		if err2 != nil {
+			return nil, err2
+		}
+
+		body, header, err2 := c.do(req, true)
+		if err2 != nil {
+			return nil, err2
+		}
+
+		var chunk []ProfileDTO
+		err2 = json.Unmarshal(body, &chunk)
+		if err2 != nil {
+			return nil, err2
+		}
+		profiles = append(profiles, chunk...)
+
+		u, err2 = nextPageURL(req.URL, header)
+		if err2 != nil {
+			return nil, err2
+		}
 	}
 
-	err = c.authenticateRequest(req)
-	if err != nil {
-		return nil, err
-	}
+	return profiles, nil
+}
 
-	body, err := c.do(req)
-	if err != nil {
-		return nil, err
+// nextPageURL returns the URL of the next page as referenced by the response's Link header (RFC 8288), nil if there is
+// no next page
+func nextPageURL(current *url.URL, header http.Header) (*url.URL, error) {
+	for _, value := range header.Values("Link") {
+		for _, link := range strings.Split(value, ",") {
+			target, params, ok := strings.Cut(link, ";")
+			if !ok || !isNextRel(params) {
+				continue
+			}
+
+			target = strings.TrimSpace(target)
+			if !strings.HasPrefix(target, "<") || !strings.HasSuffix(target, ">") {
+				return nil, fmt.Errorf("invalid link to next page: %s", link)
+			}
+
+			next, err := current.Parse(strings.Trim(target, "<>"))
+			if err != nil {
+				return nil, fmt.Errorf("invalid link to next page: %w", err)
+			}
+
+			// Requests are authenticated, so never follow links to anywhere else
+			if next.Scheme != current.Scheme || next.Host != current.Host {
+				return nil, fmt.Errorf("link to next page points to another host: %s", next.Redacted())
+			}
+
+			return next, nil
+		}
 	}
 
-	var profiles []ProfileDTO
-	err = json.Unmarshal(body, &profiles)
-	if err != nil {
-		return nil, err
+	return nil, nil
+}
+
+func isNextRel(params string) bool {
+	for _, param := range strings.Split(params, ";") {
+		key, value, ok := strings.Cut(strings.TrimSpace(param), "=")
+		if !ok || !strings.EqualFold(key, "rel") {
+			continue
+		}
+
+		for _, rel := range strings.Fields(strings.Trim(value, "\"")) {
+			if strings.EqualFold(rel, "next") {
+				return true
+			}
+		}
 	}
 
-	return profiles, nil
+	return false
 }
 
 func (c *Client) createRequest(method string, u string, body io.Reader) (*http.Request, error) {
-	req, err := http.NewRequest(method, u, body)
+	req, err := http.NewRequestWithContext(c.ctx, method, u, body)
 	if err != nil {
 		return nil, err
 	}
@@ -199,40 +360,123 @@ func (c *Client) authenticateRequest(req *http.Request) error {
 	return nil
 }
 
-func (c *Client) do(req *http.Request) ([]byte, error) {
-	res, err := c.client.Do(req)
-	if err != nil {
-		return nil, err
+// do sends the request, retrying as per the retry policy. Requests which are not idempotent are only retried if they
+// were not sent at all, since the server may have processed them even if the response indicated an error.
+func (c *Client) do(req *http.Request, idempotent bool) ([]byte, http.Header, error) {
+	wait := c.retryDelay
+	for attempt := 1; ; attempt++ {
+		body, header, err := c.doOnce(req)
+		if err == nil || attempt >= c.maxAttempts || !isRetryable(err) || (!idempotent && !isUnsent(err)) {
+			return body, header, err
+		}
+
+		// Cancelling (or a deadline of) the context is final, never retry or report a retry after it
+		if req.Context().Err() != nil {
+			return nil, nil, err
+		}
+
+		if c.onRetry != nil {
+			c.onRetry(attempt+1, c.maxAttempts, wait)
+		}
+		select {
+		case <-req.Context().Done():
+			return nil, nil, req.Context().Err()
+		case <-time.After(wait):
+		}
+		wait *= 2
+
+		// Body has been consumed by the failed attempt, so it needs to be re-created
+		if req.GetBody != nil {
+			if req.Body, err = req.GetBody(); err != nil {
+				return nil, nil, err
+			}
+		}
 	}
+}
 
-	if res.StatusCode != http.StatusOK {
-		return nil, newRequestError(req.URL, res.StatusCode)
+func (c *Client) doOnce(req *http.Request) ([]byte, http.Header, error) {
+	res, err := c.client.Do(req)
+	if err != nil {
+		if isTLSError(err) {
+			return nil, nil, newTLSError(req.URL, err)
+		}
+		return nil, nil, err
 	}
 
 	body, err := io.ReadAll(res.Body)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
 	err = res.Body.Close()
 	if err != nil {
-		return nil, err
+		return nil, nil, err
+	}
+
+	if res.StatusCode != http.StatusOK {
+		return nil, nil, newRequestError(req.URL, res.StatusCode)
 	}
 
 	// Cannot be an error response if not an object, skip error check and return
 	if !bytes.HasPrefix(body, []byte("{")) || !bytes.HasSuffix(body, []byte("}")) {
-		return body, nil
+		return body, res.Header, nil
 	}
 
 	var e errorResponse
 	err = json.Unmarshal(body, &e)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
 	if e.Error != nil {
-		return nil, newAPIError(e.Error.Code, e.Error.Message)
+		return nil, nil, newAPIError(e.Error.Code, e.Error.Message)
+	}
+
+	return body, res.Header, nil
+}
+
+// isRetryable checks whether a request might succeed if attempted again, which is the case for network errors and
+// server-side issues (but not for errors returned by the API)
+func isRetryable(err error) bool {
+	// Certificate issues will not resolve themselves
+	var te *TLSError
+	if errors.As(err, &te) {
+		return false
 	}
 
-	return body, nil
+	var re *RequestError
+	if errors.As(err, &re) {
+		return re.StatusCode >= http.StatusInternalServerError || re.StatusCode == http.StatusTooManyRequests
+	}
+
+	// Aborting the request is final (the context's error is wrapped in an *url.Error as well)
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+
+	// Errors returned by the http client are always wrapped in an *url.Error
+	var ue *url.Error
+	return errors.As(err, &ue)
+}
+
+// isUnsent checks whether a request failed without the server processing it, which is the case if no connection could
+// be established or the server rejected it due to rate limiting
+func isUnsent(err error) bool {
+	var re *RequestError
+	if errors.As(err, &re) {
+		return re.StatusCode == http.StatusTooManyRequests
+	}
+
+	var oe *net.OpError
+	return errors.As(err, &oe) && oe.Op == "dial"
+}
+
+// isTLSError checks whether a request failed due to the server's certificate not being trusted or not being a TLS
+// server at all (e.g. a proxy answering in plain text)
+func isTLSError(err error) bool {
+	var uae x509.UnknownAuthorityError
+	var cie x509.CertificateInvalidError
+	var he x509.HostnameError
+	var rhe tls.RecordHeaderError
+	return errors.As(err, &uae) || errors.As(err, &cie) || errors.As(err, &he) || errors.As(err, &rhe)
 }
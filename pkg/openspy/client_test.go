@@ -0,0 +1,279 @@
+package openspy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newTestClient returns an authenticated client for the given test server
+func newTestClient(server *httptest.Server) *Client {
+	c := New(server.URL+"/api/", 5)
+	c.authToken = "token"
+	return c
+}
+
+func TestGetProfiles(t *testing.T) {
+	pages := map[string]string{
+		"":  `[{"id":1,"nick":"mister249","uniquenick":"mister249","namespaceid":1},{"id":2,"nick":"mister250","uniquenick":"mister250","namespaceid":1}]`,
+		"2": `[{"id":3,"nick":"mister251","uniquenick":"mister251","namespaceid":1}]`,
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/profile" || r.Header.Get("Authorization") != "Bearer token" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		page := r.URL.Query().Get("page")
+		if page == "" {
+			w.Header().Set("Link", `</api/profile?page=2>; rel="next"`)
+		}
+		_, _ = w.Write([]byte(pages[page]))
+	}))
+	defer server.Close()
+
+	profiles, err := newTestClient(server).GetProfiles()
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	want := []string{"mister249", "mister250", "mister251"}
+	if len(profiles) != len(want) {
+		t.Fatalf("expected %d profiles, got %d", len(want), len(profiles))
+	}
+	for i, p := range profiles {
+		if p.UniqueNick != want[i] {
+			t.Errorf("expected profile %d to be %q, got %q", i, want[i], p.UniqueNick)
+		}
+	}
+}
+
+func TestGetProfilesPageLimit(t *testing.T) {
+	var requests int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+
+		// Always link to another page, as a misbehaving server might
+		page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+		w.Header().Set("Link", fmt.Sprintf(`</api/profile?page=%d>; rel="next"`, page+1))
+		_, _ = w.Write([]byte(`[]`))
+	}))
+	defer server.Close()
+
+	_, err := newTestClient(server).GetProfiles()
+	if err == nil || !strings.Contains(err.Error(), "exceeds") {
+		t.Fatalf("expected error about exceeding the page limit, got %v", err)
+	}
+
+	if requests != maxProfilePages {
+		t.Errorf("expected %d requests, got %d", maxProfilePages, requests)
+	}
+}
+
+func TestGetProfilesCrossHostLink(t *testing.T) {
+	var leaked bool
+	other := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		leaked = true
+		_, _ = w.Write([]byte(`[]`))
+	}))
+	defer other.Close()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Link", fmt.Sprintf(`<%s/api/profile?page=2>; rel="next"`, other.URL))
+		_, _ = w.Write([]byte(`[]`))
+	}))
+	defer server.Close()
+
+	_, err := newTestClient(server).GetProfiles()
+	if err == nil || !strings.Contains(err.Error(), "another host") {
+		t.Fatalf("expected error about link to another host, got %v", err)
+	}
+
+	if leaked {
+		t.Errorf("expected no request to the other host")
+	}
+}
+
+func TestNextPageURL(t *testing.T) {
+	current, err := url.Parse("http://account.openspy.net/api/profile")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		link    string
+		want    string
+		wantErr bool
+	}{
+		{
+			name: "no link",
+		},
+		{
+			name: "relative link",
+			link: `</api/profile?page=2>; rel="next"`,
+			want: "http://account.openspy.net/api/profile?page=2",
+		},
+		{
+			name: "absolute link",
+			link: `<http://account.openspy.net/api/profile?page=2>; rel=next`,
+			want: "http://account.openspy.net/api/profile?page=2",
+		},
+		{
+			name: "next among other links",
+			link: `</api/profile?page=1>; rel="prev first", </api/profile?page=3>; rel="next"`,
+			want: "http://account.openspy.net/api/profile?page=3",
+		},
+		{
+			name: "only other links",
+			link: `</api/profile?page=1>; rel="prev"`,
+		},
+		{
+			name:    "link to another host",
+			link:    `<http://example.com/api/profile?page=2>; rel="next"`,
+			wantErr: true,
+		},
+		{
+			name:    "link to another scheme",
+			link:    `<https://account.openspy.net/api/profile?page=2>; rel="next"`,
+			wantErr: true,
+		},
+		{
+			name:    "malformed link",
+			link:    `/api/profile?page=2; rel="next"`,
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			header := http.Header{}
+			if tt.link != "" {
+				header.Set("Link", tt.link)
+			}
+
+			got, err := nextPageURL(current, header)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("expected error, got %v", got)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("expected no error, got %v", err)
+			}
+			if tt.want == "" {
+				if got != nil {
+					t.Errorf("expected no next page, got %s", got)
+				}
+				return
+			}
+			if got == nil || got.String() != tt.want {
+				t.Errorf("expected %s, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestDoRetries(t *testing.T) {
+	tests := []struct {
+		name         string
+		send         func(c *Client) error
+		statusCodes  []int
+		wantRequests int
+		wantErr      bool
+	}{
+		{
+			name:         "idempotent request retried after server error",
+			send:         func(c *Client) error { _, err := c.GetProfiles(); return err },
+			statusCodes:  []int{http.StatusInternalServerError, http.StatusBadGateway, http.StatusOK},
+			wantRequests: 3,
+		},
+		{
+			name:         "non-idempotent request not retried after server error",
+			send:         func(c *Client) error { return c.CreateProfile("mister249", 1) },
+			statusCodes:  []int{http.StatusInternalServerError, http.StatusOK},
+			wantRequests: 1,
+			wantErr:      true,
+		},
+		{
+			name:         "non-idempotent request retried after being rate limited",
+			send:         func(c *Client) error { return c.CreateProfile("mister249", 1) },
+			statusCodes:  []int{http.StatusTooManyRequests, http.StatusOK},
+			wantRequests: 2,
+		},
+		{
+			name:         "non-idempotent request not retried after client error",
+			send:         func(c *Client) error { return c.CreateAccount("mister249@example.com", "secret", 0) },
+			statusCodes:  []int{http.StatusBadRequest, http.StatusOK},
+			wantRequests: 1,
+			wantErr:      true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var requests int
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				status := tt.statusCodes[requests]
+				requests++
+
+				w.WriteHeader(status)
+				if r.Method == http.MethodGet {
+					_, _ = w.Write([]byte(`[]`))
+				} else {
+					_, _ = w.Write([]byte(`{}`))
+				}
+			}))
+			defer server.Close()
+
+			c := newTestClient(server)
+			c.SetRetryPolicy(len(tt.statusCodes), time.Millisecond)
+
+			err := tt.send(c)
+			if tt.wantErr && err == nil {
+				t.Errorf("expected error, got nil")
+			} else if !tt.wantErr && err != nil {
+				t.Errorf("expected no error, got %v", err)
+			}
+
+			if requests != tt.wantRequests {
+				t.Errorf("expected %d requests, got %d", tt.wantRequests, requests)
+			}
+		})
+	}
+}
+
+func TestDoCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Cancel while the request is in flight
+		cancel()
+		<-r.Context().Done()
+	}))
+	defer server.Close()
+
+	c := newTestClient(server)
+	c.SetContext(ctx)
+	c.SetRetryPolicy(3, time.Millisecond)
+	var retries int
+	c.OnRetry(func(attempt, maxAttempts int, wait time.Duration) {
+		retries++
+	})
+
+	if _, err := c.GetProfiles(); !errors.Is(err, context.Canceled) {
+		t.Errorf("expected %v, got %v", context.Canceled, err)
+	}
+	if retries != 0 {
+		t.Errorf("expected no retries, got %d", retries)
+	}
+}